@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheFile persists the last successful responses of every instance, so an
+// exporter that restarts can serve recent data until its first fetch succeeds.
+// One file holds all instances, keyed by base URL.
+type cacheFile struct {
+	path   string
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is the persisted state of a single instance
+type cacheEntry struct {
+	Status     *StatusResponse `json:"status,omitempty"`
+	StatusTime time.Time       `json:"status_time"`
+	Data       *OCSResponse    `json:"data,omitempty"`
+	DataTime   time.Time       `json:"data_time"`
+}
+
+// loadCacheFile reads the cache file at path. A missing or unreadable file
+// yields an empty cache; the exporter then starts cold as it would without one.
+func loadCacheFile(path string, maxAge time.Duration) *cacheFile {
+	f := &cacheFile{path: path, maxAge: maxAge, entries: map[string]cacheEntry{}}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: reading cache file %s: %v", path, err)
+		}
+		return f
+	}
+	if err := json.Unmarshal(b, &f.entries); err != nil {
+		log.Printf("Warning: parsing cache file %s: %v", path, err)
+		f.entries = map[string]cacheEntry{}
+	}
+	return f
+}
+
+// entry returns the persisted state of an instance, without any response
+// older than the maximum age
+func (f *cacheFile) entry(baseURL string) cacheEntry {
+	f.mu.Lock()
+	entry := f.entries[baseURL]
+	f.mu.Unlock()
+
+	if time.Since(entry.StatusTime) > f.maxAge {
+		entry.Status = nil
+	}
+	if time.Since(entry.DataTime) > f.maxAge {
+		entry.Data = nil
+	}
+	return entry
+}
+
+// save records the state of an instance and rewrites the file. The file is
+// replaced atomically so a crash mid-write never leaves a truncated cache.
+func (f *cacheFile) save(baseURL string, entry cacheEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.entries[baseURL] = entry
+	b, err := json.Marshal(f.entries)
+	if err != nil {
+		log.Printf("Warning: encoding cache file: %v", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		log.Printf("Warning: writing cache file %s: %v", f.path, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		log.Printf("Warning: writing cache file %s: %v", f.path, err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("Warning: writing cache file %s: %v", f.path, err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		log.Printf("Warning: writing cache file %s: %v", f.path, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheFileSavesNonFiniteCPULoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	data := &OCSResponse{}
+	data.OCS.Data.Nextcloud.System.CPULoad = LoadAverages{math.NaN(), 0.4, math.Inf(1)}
+
+	loadCacheFile(path, time.Hour).save("https://cloud.example.com", cacheEntry{Data: data, DataTime: time.Now()})
+
+	entry := loadCacheFile(path, time.Hour).entry("https://cloud.example.com")
+	if entry.Data == nil {
+		t.Fatal("payload with a NaN cpuload was not saved")
+	}
+	got := entry.Data.OCS.Data.Nextcloud.System.CPULoad
+	if len(got) != 3 || !math.IsNaN(got[0]) || got[1] != 0.4 || !math.IsNaN(got[2]) {
+		t.Errorf("got cpuload %v, want [NaN 0.4 NaN]", got)
+	}
+}
+
+func TestCacheFileMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	loadCacheFile(path, time.Hour).save("https://cloud.example.com", cacheEntry{
+		Data:     &OCSResponse{},
+		DataTime: time.Now().Add(-2 * time.Hour),
+	})
+
+	if entry := loadCacheFile(path, time.Hour).entry("https://cloud.example.com"); entry.Data != nil {
+		t.Error("response older than the maximum age was restored")
+	}
+}
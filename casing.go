@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// serverinfoKeys maps the lowercased json key paths of OCSResponse (e.g.
+// "ocs.data.nextcloud.system.freespace") to their canonical casing. The
+// systemDataFallbacks spellings map to the key they stand in for.
+var serverinfoKeys = func() map[string]string {
+	keys := jsonKeys(reflect.TypeFor[OCSResponse](), "", map[string]string{})
+	const system = "ocs.data.nextcloud.system."
+	for key, fallback := range systemDataFallbacks {
+		keys[strings.ToLower(system+fallback)] = system + key
+	}
+	return keys
+}()
+
+// jsonKeys collects the json tag paths of t and its nested structs below prefix,
+// keyed by their lowercase form
+func jsonKeys(t reflect.Type, prefix string, keys map[string]string) map[string]string {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return keys
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		keys[strings.ToLower(path)] = path
+		jsonKeys(field.Type, path+".", keys)
+	}
+	return keys
+}
+
+// casingVariants returns the key paths in body that match a serverinfo field
+// only case-insensitively (e.g. "freeSpace" for "freespace") or through a
+// systemDataFallbacks spelling (e.g. "free_space").
+// Keys are compared by their full path, so app ids or storage names that happen
+// to equal a field name elsewhere are not counted.
+func casingVariants(body []byte) []string {
+	var raw any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+	var variants []string
+	var walk func(v any, lowerPrefix, prefix string)
+	walk = func(v any, lowerPrefix, prefix string) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				lowerPath := lowerPrefix + strings.ToLower(key)
+				canonical, ok := serverinfoKeys[lowerPath]
+				if !ok {
+					continue
+				}
+				path := prefix + key
+				if path != canonical {
+					variants = append(variants, path)
+				}
+				walk(value, lowerPath+".", canonical+".")
+			}
+		case []any:
+			for _, value := range v {
+				walk(value, lowerPrefix, prefix)
+			}
+		}
+	}
+	walk(raw, "", "")
+	return variants
+}
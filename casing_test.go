@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCasingVariants(t *testing.T) {
+	body := []byte(`{"ocs": {"data": {
+		"nextcloud": {
+			"system": {"freeSpace": 1, "cpuload": [0.1], "apps": {"installed": {"Version": "1.2.0"}}},
+			"Storage": {"num_users": 1}
+		},
+		"server": {"database": {"Size": 1, "type": "mysql"}}
+	}}}`)
+	got := casingVariants(body)
+	slices.Sort(got)
+	want := []string{
+		"ocs.data.nextcloud.Storage",
+		"ocs.data.nextcloud.system.freeSpace",
+		"ocs.data.server.database.Size",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCasingVariantsCanonical(t *testing.T) {
+	body := []byte(`{"ocs": {"data": {"nextcloud": {"system": {"freespace": 1, "version": "28.0.1.1"}}}}}`)
+	if got := casingVariants(body); len(got) != 0 {
+		t.Errorf("got %q, want none", got)
+	}
+}
+
+func TestCasingVariantsFallback(t *testing.T) {
+	body := []byte(`{"ocs": {"data": {"nextcloud": {"system": {"free_space": 1}}}}}`)
+	got := casingVariants(body)
+	if want := []string{"ocs.data.nextcloud.system.free_space"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,9 +26,49 @@ import (
 
 // NextcloudCollector implements prometheus.Collector
 type NextcloudCollector struct {
-	config  *Config
-	client  *http.Client
-	metrics *MetricDescriptors
+	config   *Config
+	instance Instance
+	client   *http.Client
+	metrics  *MetricDescriptors
+
+	// Number of Collect invocations since process start
+	scrapes atomic.Uint64
+
+	// Number of Collect invocations currently running
+	inFlight atomic.Int64
+
+	// Number of panics recovered while building metrics
+	collectPanics atomic.Uint64
+
+	// Number of non-finite CPU load values skipped
+	invalidCPULoad atomic.Uint64
+
+	// Set once the first serverinfo fetch has succeeded
+	fetchedOnce atomic.Bool
+
+	// Response body bytes read per upstream endpoint
+	bytesRead map[string]*atomic.Uint64
+
+	// Serverinfo fetches per authentication outcome
+	authResults map[string]*atomic.Uint64
+
+	// Number of status.php responses with fields unknown to StatusResponse (with -strict-decode)
+	unknownFields atomic.Uint64
+
+	// Number of serverinfo keys that only matched a field case-insensitively (with -strict-decode)
+	casingVariants atomic.Uint64
+
+	// Label values of nextcloud_exporter_features_info, computed once from the config
+	features []string
+
+	// Whether the base URL uses https, derived once at startup
+	tlsEnabled bool
+
+	// Hash of the effective configuration, computed once at startup
+	configHash uint32
+
+	// Host of the base URL for nextcloud_exporter_target_info, derived once at startup
+	targetHost string
 
 	// Caching for rate limiting
 	cacheMu         sync.RWMutex
@@ -25,14 +76,96 @@ type NextcloudCollector struct {
 	cachedData      *OCSResponse
 	lastFetchTime   time.Time
 	lastStatusFetch time.Time
+
+	cachedCapabilities    map[string]bool
+	lastCapabilitiesFetch time.Time
+
+	cachedExtras map[string]cachedExtra
+
+	// Delta tracking between fetches
+	usersAdded             int
+	sharesCreated          int
+	activeUsersDailyGrowth int
+
+	// Errors of the most recent fetch attempts (nil after a success)
+	lastStatusErr error
+	lastDataErr   error
+
+	// Whether the most recent serverinfo fetch attempt failed with a timeout
+	lastFetchTimedOut bool
+
+	// Whether the most recent serverinfo fetch attempt returned "data": []
+	lastFetchEmptyData bool
+
+	// HTTP status code of the most recent serverinfo response (0 until one is received, or when the last request got none)
+	serverinfoStatusCode int
+
+	// Expiry of the upstream's leaf certificate (zero until an HTTPS response is received)
+	tlsNotAfter time.Time
+
+	// Exponential moving average of CPU load per interval, across scrapes
+	cpuLoadEMA map[string]float64
+
+	// Highest OPcache used memory seen since process start
+	opcacheUsedMax int64
+
+	// Responses persisted across restarts (nil unless -cache-file is set)
+	cacheFile *cacheFile
 }
 
-// NewNextcloudCollector creates a new collector with the given configuration
-func NewNextcloudCollector(config *Config) *NextcloudCollector {
+// NewNextcloudCollector creates a new collector for a single instance with the given configuration
+func NewNextcloudCollector(config *Config, instance Instance) *NextcloudCollector {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.RootCAs != nil || config.TLSServerName != "" || config.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:            config.RootCAs,
+			ServerName:         config.TLSServerName,
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		}
+	}
+
+	enabled := config.enabledFeatures()
+	features := make([]string, len(exporterFeatures))
+	for i, name := range exporterFeatures {
+		features[i] = strconv.FormatBool(enabled[name])
+	}
+
+	if config.Resolver != "" {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver: &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, config.Resolver)
+				},
+			},
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	bytesRead := make(map[string]*atomic.Uint64, len(upstreamEndpoints))
+	for _, endpoint := range upstreamEndpoints {
+		bytesRead[endpoint] = &atomic.Uint64{}
+	}
+	authResults := make(map[string]*atomic.Uint64, len(authResultLabels))
+	for _, result := range authResultLabels {
+		authResults[result] = &atomic.Uint64{}
+	}
+
 	return &NextcloudCollector{
-		config: config,
+		config:      config,
+		instance:    instance,
+		features:    features,
+		configHash:  config.hash(),
+		targetHost:  baseURLHost(instance.BaseURL),
+		bytesRead:   bytesRead,
+		authResults: authResults,
+		tlsEnabled:  strings.EqualFold(baseURLScheme(instance.BaseURL), "https"),
 		client: &http.Client{
-			Timeout: config.Timeout,
+			Timeout:   config.Timeout,
+			Transport: transport,
 		},
 		metrics: NewMetricDescriptors(),
 	}
@@ -45,8 +178,41 @@ func (c *NextcloudCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector
 func (c *NextcloudCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeInFlight, prometheus.GaugeValue, float64(c.inFlight.Add(1)))
+	defer c.inFlight.Add(-1)
+	ch <- prometheus.MustNewConstMetric(c.metrics.ScrapesTotal, prometheus.CounterValue, float64(c.scrapes.Add(1)))
+	ch <- prometheus.MustNewConstMetric(c.metrics.ExporterAuthConfigured, c.infoValueType(), 1, c.authMethod())
+	ch <- prometheus.MustNewConstMetric(c.metrics.ExporterFeaturesInfo, c.infoValueType(), 1, c.features...)
+	ch <- prometheus.MustNewConstMetric(c.metrics.ExporterConfigHash, prometheus.GaugeValue, float64(c.configHash))
+	ch <- prometheus.MustNewConstMetric(c.metrics.ExporterTargetInfo, c.infoValueType(), 1, c.targetHost)
+	ch <- prometheus.MustNewConstMetric(c.metrics.ExporterScrapeTime, prometheus.GaugeValue, float64(time.Now().Unix()))
+
+	// scrape_success is emitted last, from a deferred block, so that it is
+	// present (as 0) even when building the serverinfo metrics panics
+	success := false
+	defer func() {
+		if r := recover(); r != nil {
+			c.collectPanics.Add(1)
+			log.Printf("Recovered from panic while collecting serverinfo metrics: %v", r)
+			success = false
+		}
+		ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeSuccess, prometheus.GaugeValue, boolToFloat(success))
+		ch <- prometheus.MustNewConstMetric(c.metrics.CollectPanicTotal, prometheus.CounterValue, float64(c.collectPanics.Load()))
+		ch <- prometheus.MustNewConstMetric(c.metrics.InvalidMetricValues, prometheus.CounterValue, float64(c.invalidCPULoad.Load()), "cpuload")
+		for _, endpoint := range upstreamEndpoints {
+			ch <- prometheus.MustNewConstMetric(c.metrics.UpstreamBytesRead, prometheus.CounterValue, float64(c.bytesRead[endpoint].Load()), endpoint)
+		}
+		for _, result := range authResultLabels {
+			ch <- prometheus.MustNewConstMetric(c.metrics.AuthResults, prometheus.CounterValue, float64(c.authResults[result].Load()), result)
+		}
+		ch <- prometheus.MustNewConstMetric(c.metrics.FieldCasingVariants, prometheus.CounterValue, float64(c.casingVariants.Load()))
+		if c.config.StrictDecode {
+			ch <- prometheus.MustNewConstMetric(c.metrics.UnknownFieldsTotal, prometheus.CounterValue, float64(c.unknownFields.Load()))
+		}
+	}()
+
 	// Fetch status data (with caching)
-	status, statusErr := c.fetchStatusCached()
+	status, statusFetched, statusErr := c.fetchStatusCached()
 	if statusErr != nil {
 		log.Printf("Error fetching status: %v", statusErr)
 	} else {
@@ -54,19 +220,68 @@ func (c *NextcloudCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	// Fetch serverinfo data (with caching)
-	data, dataErr := c.fetchDataCached()
+	data, dataFetched, dataErr := c.fetchDataCached()
+
+	// Maintenance consistency needs both sources answering in this scrape, so a
+	// cached answer is never compared with a fresh one; serverinfo agrees with
+	// an enabled maintenance mode by answering 503
+	c.cacheMu.RLock()
+	serverinfoStatusCode := c.serverinfoStatusCode
+	c.cacheMu.RUnlock()
+	if statusFetched && dataFetched && serverinfoStatusCode != 0 {
+		consistent := status.Maintenance == (serverinfoStatusCode == http.StatusServiceUnavailable)
+		ch <- prometheus.MustNewConstMetric(c.metrics.MaintenanceStateConsistent, prometheus.GaugeValue, boolToFloat(consistent))
+	}
+
+	// Each source is cached independently; flag a mix of fresh and stale data
+	c.cacheMu.RLock()
+	statusFresh := c.cachedStatus != nil && time.Since(c.lastStatusFetch) < c.config.FetchInterval
+	dataFresh := c.cachedData != nil && time.Since(c.lastFetchTime) < c.config.FetchInterval
+	validFor := max(c.config.FetchInterval-time.Since(c.lastFetchTime), 0)
+	c.cacheMu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.metrics.CachePartial, prometheus.GaugeValue, boolToFloat(statusFresh != dataFresh))
+	ch <- prometheus.MustNewConstMetric(c.metrics.CacheValidFor, prometheus.GaugeValue, validFor.Seconds())
+
+	c.cacheMu.RLock()
+	tlsNotAfter := c.tlsNotAfter
+	timedOut := c.lastFetchTimedOut
+	emptyData := c.lastFetchEmptyData
+	c.cacheMu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeTimedOut, prometheus.GaugeValue, boolToFloat(timedOut))
+	ch <- prometheus.MustNewConstMetric(c.metrics.UpstreamTLSEnabled, prometheus.GaugeValue, boolToFloat(c.tlsEnabled))
+	ch <- prometheus.MustNewConstMetric(c.metrics.ServerinfoEmptyData, prometheus.GaugeValue, boolToFloat(emptyData))
+	if !tlsNotAfter.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.metrics.UpstreamTLSCertExpiry, prometheus.GaugeValue, time.Until(tlsNotAfter).Seconds())
+		ch <- prometheus.MustNewConstMetric(c.metrics.UpstreamTLSCertNotAfter, prometheus.GaugeValue, float64(tlsNotAfter.Unix()))
+	}
+
+	if c.config.ScrapeCapabilities {
+		capabilities, err := c.fetchCapabilitiesCached()
+		if err != nil {
+			log.Printf("Error fetching capabilities: %v", err)
+		} else {
+			c.collectCapabilityMetrics(ch, capabilities)
+		}
+	}
+	c.collectExtraMetrics(ch)
+
 	if dataErr != nil {
 		log.Printf("Error fetching data: %v", dataErr)
-		ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeSuccess, prometheus.GaugeValue, 0)
+		ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeError, prometheus.GaugeValue, 1, failureReason(dataErr))
+		if c.config.EmitZerosOnFailure {
+			c.collectNaNMetrics(ch)
+		}
 		return
 	}
 
-	ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeSuccess, prometheus.GaugeValue, 1)
+	success = true
 	c.collectAllMetrics(ch, data)
 }
 
 func (c *NextcloudCollector) collectStatusMetrics(ch chan<- prometheus.Metric, status *StatusResponse) {
-	ch <- prometheus.MustNewConstMetric(c.metrics.StatusInfo, prometheus.GaugeValue, 1,
+	defer c.recoverCollectPanic("status")
+
+	ch <- prometheus.MustNewConstMetric(c.metrics.StatusInfo, c.infoValueType(), 1,
 		status.Version, status.VersionString, status.ProductName, status.Edition)
 	ch <- prometheus.MustNewConstMetric(c.metrics.StatusInstalled, prometheus.GaugeValue, boolToFloat(status.Installed))
 	ch <- prometheus.MustNewConstMetric(c.metrics.StatusMaintenance, prometheus.GaugeValue, boolToFloat(status.Maintenance))
@@ -74,19 +289,47 @@ func (c *NextcloudCollector) collectStatusMetrics(ch chan<- prometheus.Metric, s
 	ch <- prometheus.MustNewConstMetric(c.metrics.StatusExtendedSupport, prometheus.GaugeValue, boolToFloat(status.ExtendedSupport))
 }
 
+// collectAllMetrics emits the serverinfo metrics. A panic here is recovered by
+// Collect, which then reports the scrape as failed.
 func (c *NextcloudCollector) collectAllMetrics(ch chan<- prometheus.Metric, data *OCSResponse) {
 	nc := data.OCS.Data.Nextcloud
 	srv := data.OCS.Data.Server
 	users := data.OCS.Data.ActiveUsers
 
 	// System metrics
-	ch <- prometheus.MustNewConstMetric(c.metrics.SystemInfo, prometheus.GaugeValue, 1, nc.System.Version)
-	ch <- prometheus.MustNewConstMetric(c.metrics.FreeSpace, prometheus.GaugeValue, float64(nc.System.FreeSpace))
+	// The release channel is only reported by some versions; it stays empty otherwise
+	ch <- prometheus.MustNewConstMetric(c.metrics.SystemInfo, c.infoValueType(), 1, nc.System.Version, nc.System.Channel)
 
-	if len(nc.System.CPULoad) >= 3 {
-		ch <- prometheus.MustNewConstMetric(c.metrics.CPULoad, prometheus.GaugeValue, nc.System.CPULoad[0], "1m")
-		ch <- prometheus.MustNewConstMetric(c.metrics.CPULoad, prometheus.GaugeValue, nc.System.CPULoad[1], "5m")
-		ch <- prometheus.MustNewConstMetric(c.metrics.CPULoad, prometheus.GaugeValue, nc.System.CPULoad[2], "15m")
+	// A fresh or broken server can report users, files and free space all as
+	// zero at once; with -skip-suspicious-zeros those metrics are left out
+	suspiciousZeros := c.config.SkipSuspiciousZeros && nc.Storage.NumUsers == 0 && nc.Storage.NumFiles == 0 && nc.System.FreeSpace == 0
+	if c.config.SkipSuspiciousZeros {
+		ch <- prometheus.MustNewConstMetric(c.metrics.SuspiciousZeroPayload, prometheus.GaugeValue, boolToFloat(suspiciousZeros))
+	}
+	if !suspiciousZeros {
+		ch <- prometheus.MustNewConstMetric(c.metrics.FreeSpace, prometheus.GaugeValue, float64(nc.System.FreeSpace))
+		if c.config.FreeSpaceWarnBytes > 0 {
+			below := int64(nc.System.FreeSpace) < c.config.FreeSpaceWarnBytes
+			ch <- prometheus.MustNewConstMetric(c.metrics.FreeSpaceBelowThreshold, prometheus.GaugeValue, boolToFloat(below))
+		}
+	}
+
+	// Emit whichever intervals are present, skipping values that would poison downstream queries
+	c.cacheMu.RLock()
+	cpuLoadEMA := maps.Clone(c.cpuLoadEMA)
+	c.cacheMu.RUnlock()
+	for i, load := range nc.System.CPULoad {
+		if i >= len(cpuLoadIntervals) {
+			break
+		}
+		if math.IsNaN(load) || math.IsInf(load, 0) {
+			c.invalidCPULoad.Add(1)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.metrics.CPULoad, prometheus.GaugeValue, load, cpuLoadIntervals[i])
+		if ema, ok := cpuLoadEMA[cpuLoadIntervals[i]]; ok {
+			ch <- prometheus.MustNewConstMetric(c.metrics.CPULoadSmoothed, prometheus.GaugeValue, ema, cpuLoadIntervals[i])
+		}
 	}
 
 	ch <- prometheus.MustNewConstMetric(c.metrics.CPUCount, prometheus.GaugeValue, float64(nc.System.CPUNum))
@@ -95,10 +338,44 @@ func (c *NextcloudCollector) collectAllMetrics(ch chan<- prometheus.Metric, data
 	ch <- prometheus.MustNewConstMetric(c.metrics.MemFree, prometheus.GaugeValue, float64(nc.System.MemFree)*1024)
 	ch <- prometheus.MustNewConstMetric(c.metrics.SwapTotal, prometheus.GaugeValue, float64(nc.System.SwapTotal)*1024)
 	ch <- prometheus.MustNewConstMetric(c.metrics.SwapFree, prometheus.GaugeValue, float64(nc.System.SwapFree)*1024)
+	ch <- prometheus.MustNewConstMetric(c.metrics.SwapConfigured, prometheus.GaugeValue, boolToFloat(nc.System.SwapTotal > 0))
+
+	if nc.System.DefaultTimezone != "" || nc.System.DefaultPhoneRegion != "" {
+		ch <- prometheus.MustNewConstMetric(c.metrics.LocaleInfo, c.infoValueType(), 1, nc.System.DefaultTimezone, nc.System.DefaultPhoneRegion)
+	}
+	if nc.System.Debug != nil {
+		ch <- prometheus.MustNewConstMetric(c.metrics.DebugEnabled, prometheus.GaugeValue, boolToFloat(*nc.System.Debug))
+	}
+
+	// Cache backends, skipping types serverinfo does not report
+	for cacheType, class := range map[string]string{
+		"local":       nc.System.MemcacheLocal,
+		"distributed": nc.System.MemcacheDistributed,
+		"locking":     nc.System.MemcacheLocking,
+	} {
+		if class != "" {
+			ch <- prometheus.MustNewConstMetric(c.metrics.CacheBackendInfo, c.infoValueType(), 1, cacheType, cacheBackend(class))
+		}
+	}
 
 	// Apps metrics
 	ch <- prometheus.MustNewConstMetric(c.metrics.AppsInstalled, prometheus.GaugeValue, float64(nc.System.Apps.NumInstalled))
 	ch <- prometheus.MustNewConstMetric(c.metrics.AppsUpdatesAvailable, prometheus.GaugeValue, float64(nc.System.Apps.NumUpdatesAvailable))
+	if security := nc.System.Apps.NumSecurityUpdatesAvailable; security.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.AppsSecurityUpdatesAvailable, prometheus.GaugeValue, security.Value)
+	}
+	installed := nc.System.installedApps()
+	if c.config.AppInfoLimit > 0 && len(installed) > 0 {
+		// Sort so the same apps are kept when the list is truncated
+		apps := slices.Sorted(maps.Keys(installed))
+		if len(apps) > c.config.AppInfoLimit {
+			c.debugf("apps: emitting %d of %d installed apps (app info limit)", c.config.AppInfoLimit, len(apps))
+			apps = apps[:c.config.AppInfoLimit]
+		}
+		for _, app := range apps {
+			ch <- prometheus.MustNewConstMetric(c.metrics.AppInfo, c.infoValueType(), 1, app, installed[app])
+		}
+	}
 
 	// Update metrics
 	updateVal := 0.0
@@ -107,60 +384,297 @@ func (c *NextcloudCollector) collectAllMetrics(ch chan<- prometheus.Metric, data
 	}
 	ch <- prometheus.MustNewConstMetric(c.metrics.UpdateAvailable, prometheus.GaugeValue, updateVal, nc.System.Update.AvailableVersion)
 
+	// The same update counts broken down by type; security only when reported
+	ch <- prometheus.MustNewConstMetric(c.metrics.UpdatesAvailable, prometheus.GaugeValue, updateVal, "core")
+	ch <- prometheus.MustNewConstMetric(c.metrics.UpdatesAvailable, prometheus.GaugeValue, float64(nc.System.Apps.NumUpdatesAvailable), "app")
+	if security := nc.System.Apps.NumSecurityUpdatesAvailable; security.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.UpdatesAvailable, prometheus.GaugeValue, security.Value, "security")
+	}
+
 	// Storage metrics
-	ch <- prometheus.MustNewConstMetric(c.metrics.UsersTotal, prometheus.GaugeValue, float64(nc.Storage.NumUsers))
-	ch <- prometheus.MustNewConstMetric(c.metrics.FilesTotal, prometheus.GaugeValue, float64(nc.Storage.NumFiles))
-	ch <- prometheus.MustNewConstMetric(c.metrics.StoragesTotal, prometheus.GaugeValue, float64(nc.Storage.NumStorages))
-	ch <- prometheus.MustNewConstMetric(c.metrics.StoragesLocalTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesLocal))
-	ch <- prometheus.MustNewConstMetric(c.metrics.StoragesHomeTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesHome))
-	ch <- prometheus.MustNewConstMetric(c.metrics.StoragesOtherTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesOther))
+	if !suspiciousZeros {
+		ch <- prometheus.MustNewConstMetric(c.metrics.UsersTotal, prometheus.GaugeValue, float64(nc.Storage.NumUsers))
+		c.cacheMu.RLock()
+		usersAdded := c.usersAdded
+		c.cacheMu.RUnlock()
+		ch <- prometheus.MustNewConstMetric(c.metrics.UsersAdded, prometheus.GaugeValue, float64(usersAdded))
+		ch <- prometheus.MustNewConstMetric(c.metrics.FilesTotal, prometheus.GaugeValue, float64(nc.Storage.NumFiles))
+		if nc.Storage.NumUsers > 0 {
+			ch <- prometheus.MustNewConstMetric(c.metrics.FilesPerUser, prometheus.GaugeValue, ratio(nc.Storage.NumFiles, nc.Storage.NumUsers))
+		}
+		for storageType, files := range map[string]OptionalFloat{
+			"home":  nc.Storage.NumFilesHome,
+			"local": nc.Storage.NumFilesLocal,
+			"other": nc.Storage.NumFilesOther,
+		} {
+			if files.Valid {
+				ch <- prometheus.MustNewConstMetric(c.metrics.FilesByStorage, prometheus.GaugeValue, files.Value, storageType)
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(c.metrics.StoragesTotal, prometheus.GaugeValue, float64(nc.Storage.NumStorages))
+		ch <- prometheus.MustNewConstMetric(c.metrics.StoragesLocalTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesLocal))
+		ch <- prometheus.MustNewConstMetric(c.metrics.StoragesHomeTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesHome))
+		ch <- prometheus.MustNewConstMetric(c.metrics.StoragesOtherTotal, prometheus.GaugeValue, float64(nc.Storage.NumStoragesOther))
+		if nc.Storage.NumStoragesExternal.Valid {
+			ch <- prometheus.MustNewConstMetric(c.metrics.StoragesExternalTotal, prometheus.GaugeValue, nc.Storage.NumStoragesExternal.Value)
+		}
+	}
 
 	// Shares metrics
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesTotal, prometheus.GaugeValue, float64(nc.Shares.NumShares))
+	c.cacheMu.RLock()
+	sharesCreated := c.sharesCreated
+	c.cacheMu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.metrics.SharesCreatedDelta, prometheus.GaugeValue, float64(sharesCreated))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesUserTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesUser))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesGroupsTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesGroups))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesLinkTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesLink))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesMailTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesMail))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesRoomTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesRoom))
+	ch <- prometheus.MustNewConstMetric(c.metrics.SharesRoomRatio, prometheus.GaugeValue, ratio(nc.Shares.NumSharesRoom, nc.Shares.NumShares))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesLinkNoPasswordTotal, prometheus.GaugeValue, float64(nc.Shares.NumSharesLinkNoPassword))
+	if c.config.LinkNoPasswordWarn > 0 {
+		exceeds := nc.Shares.NumSharesLinkNoPassword > c.config.LinkNoPasswordWarn
+		ch <- prometheus.MustNewConstMetric(c.metrics.SharesLinkNoPasswordExceedsThreshold, prometheus.GaugeValue, boolToFloat(exceeds))
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics.SharesLinkNoPasswordRatio, prometheus.GaugeValue, ratio(nc.Shares.NumSharesLinkNoPassword, nc.Shares.NumSharesLink))
+	ch <- prometheus.MustNewConstMetric(c.metrics.SharesLinkToUserRatio, prometheus.GaugeValue, ratio(nc.Shares.NumSharesLink, nc.Shares.NumSharesUser))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesFederatedSentTotal, prometheus.GaugeValue, float64(nc.Shares.NumFedSharesSent))
 	ch <- prometheus.MustNewConstMetric(c.metrics.SharesFederatedReceivedTotal, prometheus.GaugeValue, float64(nc.Shares.NumFedSharesReceived))
 
 	// Server metrics
+	ch <- prometheus.MustNewConstMetric(c.metrics.PHPInfo, c.infoValueType(), 1, srv.PHP.Version)
+	if eolDate, eol, ok := phpEOL(srv.PHP.Version, time.Now()); ok {
+		ch <- prometheus.MustNewConstMetric(c.metrics.PHPVersionEOL, prometheus.GaugeValue, boolToFloat(eol), eolDate)
+	}
 	ch <- prometheus.MustNewConstMetric(c.metrics.PHPMemoryLimit, prometheus.GaugeValue, float64(srv.PHP.MemoryLimit))
+	if nc.Storage.NumUsers > 0 && srv.PHP.MemoryLimit > 0 {
+		memoryLimitMB := float64(srv.PHP.MemoryLimit) / (1 << 20)
+		ch <- prometheus.MustNewConstMetric(c.metrics.UsersPerPHPMemoryMB, prometheus.GaugeValue, float64(nc.Storage.NumUsers)/memoryLimitMB)
+	}
 	ch <- prometheus.MustNewConstMetric(c.metrics.PHPUploadMaxFilesize, prometheus.GaugeValue, float64(srv.PHP.UploadMaxFilesize))
 	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheMemoryUsed, prometheus.GaugeValue, float64(srv.PHP.OPcache.MemoryUsage.UsedMemory))
+	c.cacheMu.Lock()
+	c.opcacheUsedMax = max(c.opcacheUsedMax, srv.PHP.OPcache.MemoryUsage.UsedMemory)
+	opcacheUsedMax := c.opcacheUsedMax
+	c.cacheMu.Unlock()
+	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheMemoryUsedMax, prometheus.GaugeValue, float64(opcacheUsedMax))
 	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheMemoryFree, prometheus.GaugeValue, float64(srv.PHP.OPcache.MemoryUsage.FreeMemory))
+	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheMemoryWasted, prometheus.GaugeValue, float64(srv.PHP.OPcache.MemoryUsage.WastedMemory))
 	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheHitRate, prometheus.GaugeValue, srv.PHP.OPcache.OPcacheStatistics.OPcacheHitRate)
+	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheHits, prometheus.CounterValue, float64(srv.PHP.OPcache.OPcacheStatistics.Hits))
+	ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheMisses, prometheus.CounterValue, float64(srv.PHP.OPcache.OPcacheStatistics.Misses))
+	// PHP reports the blacklist miss ratio as a percentage
+	if ratio := srv.PHP.OPcache.OPcacheStatistics.BlacklistMissRatio; ratio.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheBlacklistMissRatio, prometheus.GaugeValue, ratio.Value/100)
+	}
+	for reason, restarts := range map[string]OptionalFloat{
+		"oom":    srv.PHP.OPcache.OPcacheStatistics.OOMRestarts,
+		"hash":   srv.PHP.OPcache.OPcacheStatistics.HashRestarts,
+		"manual": srv.PHP.OPcache.OPcacheStatistics.ManualRestarts,
+	} {
+		if restarts.Valid {
+			ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheRestarts, prometheus.CounterValue, restarts.Value, reason)
+		}
+	}
+	if jit := srv.PHP.OPcache.JIT; jit.BufferSize.Valid && jit.BufferFree.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheJITBufferUsed, prometheus.GaugeValue, jit.BufferSize.Value-jit.BufferFree.Value)
+		ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheJITBufferFree, prometheus.GaugeValue, jit.BufferFree.Value)
+	}
+
+	// 0 means no restart since PHP started
+	if lastRestart := srv.PHP.OPcache.OPcacheStatistics.LastRestartTime; lastRestart.Valid && lastRestart.Value > 0 {
+		ch <- prometheus.MustNewConstMetric(c.metrics.PHPOpcacheLastRestart, prometheus.GaugeValue, unixSeconds(lastRestart.Value))
+	}
 
-	// Database size (parse string to int)
-	if dbSize, err := strconv.ParseInt(srv.Database.Size, 10, 64); err == nil {
-		ch <- prometheus.MustNewConstMetric(c.metrics.DatabaseSize, prometheus.GaugeValue, float64(dbSize))
+	ch <- prometheus.MustNewConstMetric(c.metrics.DatabaseInfo, c.infoValueType(), 1, srv.Database.Type, srv.Database.Version)
+
+	// Database size, when the database type reports a usable one
+	if srv.Database.Size.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.DatabaseSize, prometheus.GaugeValue, srv.Database.Size.Value)
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics.DatabaseSizeAvailable, prometheus.GaugeValue, boolToFloat(srv.Database.Size.Valid))
+	if missing := srv.Database.MissingIndices; missing.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.DatabaseMissingIndices, prometheus.GaugeValue, missing.Value)
+	}
+	if pending := srv.Database.PendingBigintConversions; pending.Valid {
+		ch <- prometheus.MustNewConstMetric(c.metrics.DatabasePendingBigintConversions, prometheus.GaugeValue, pending.Value)
 	}
 
-	// Active users metrics
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last5Minutes), "5min")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last1Hour), "1hour")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last24Hours), "24hours")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last7Days), "7days")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last1Month), "1month")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last3Months), "3months")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.Last6Months), "6months")
-	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(users.LastYear), "1year")
+	// Active users metrics, limited to the configured periods
+	activeUsers := users.byPeriod()
+	for _, period := range c.config.ActiveUserPeriods {
+		ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, float64(activeUsers[period]), period)
+	}
+	c.cacheMu.RLock()
+	activeUsersDailyGrowth := c.activeUsersDailyGrowth
+	c.cacheMu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsersDailyGrowth, prometheus.GaugeValue, float64(activeUsersDailyGrowth))
+}
+
+func (c *NextcloudCollector) collectCapabilityMetrics(ch chan<- prometheus.Metric, capabilities map[string]bool) {
+	defer c.recoverCollectPanic("capabilities")
+
+	for name, enabled := range capabilities {
+		ch <- prometheus.MustNewConstMetric(c.metrics.Capability, prometheus.GaugeValue, boolToFloat(enabled), name)
+	}
+}
+
+// updateCPULoadEMA folds the CPU load samples of a freshly fetched payload into
+// each interval's moving average, skipping non-finite values. The first sample
+// seeds the average. The caller must hold cacheMu.
+func (c *NextcloudCollector) updateCPULoadEMA(loads []float64) {
+	if c.cpuLoadEMA == nil {
+		c.cpuLoadEMA = map[string]float64{}
+	}
+	for i, load := range loads {
+		if i >= len(cpuLoadIntervals) {
+			break
+		}
+		if math.IsNaN(load) || math.IsInf(load, 0) {
+			continue
+		}
+		interval := cpuLoadIntervals[i]
+		ema, ok := c.cpuLoadEMA[interval]
+		if !ok {
+			ema = load
+		} else {
+			alpha := c.config.CPULoadEMAAlpha
+			ema = alpha*load + (1-alpha)*ema
+		}
+		c.cpuLoadEMA[interval] = ema
+	}
+}
+
+// recoverCollectPanic keeps a panic raised while building metrics (e.g. by
+// MustNewConstMetric on an unexpected value) from taking down the exporter.
+// It must be deferred directly.
+func (c *NextcloudCollector) recoverCollectPanic(section string) {
+	if r := recover(); r != nil {
+		c.collectPanics.Add(1)
+		log.Printf("Recovered from panic while collecting %s metrics: %v", section, r)
+	}
 }
 
-// fetchStatusCached returns cached status if within fetch interval, otherwise fetches fresh data
-func (c *NextcloudCollector) fetchStatusCached() (*StatusResponse, error) {
+// collectNaNMetrics emits the serverinfo metrics as NaN so that dashboards show
+// "no data" instead of a gap when a fetch fails and nothing is cached. CPU load
+// is left out since it is never emitted as a non-finite value.
+func (c *NextcloudCollector) collectNaNMetrics(ch chan<- prometheus.Metric) {
+	nan := math.NaN()
+	for _, desc := range c.metrics.serverinfoValueDescs() {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, nan)
+	}
+	for _, period := range c.config.ActiveUserPeriods {
+		ch <- prometheus.MustNewConstMetric(c.metrics.ActiveUsers, prometheus.GaugeValue, nan, period)
+	}
+}
+
+// authMethod names how requests to the instance are authenticated
+func (c *NextcloudCollector) authMethod() string {
+	if c.instance.Token == "" {
+		return "none"
+	}
+	return "nc_token"
+}
+
+// infoValueType returns the configured value type for info-style metrics
+func (c *NextcloudCollector) infoValueType() prometheus.ValueType {
+	if c.config.InfoMetricType == "untyped" {
+		return prometheus.UntypedValue
+	}
+	return prometheus.GaugeValue
+}
+
+// debugf logs a message prefixed with the instance URL when debug logging is enabled
+func (c *NextcloudCollector) debugf(format string, args ...any) {
+	if c.config.LogLevel != "debug" {
+		return
+	}
+	log.Printf("[debug] %s: "+format, append([]any{c.instance.BaseURL}, args...)...)
+}
+
+// Ready reports whether a serverinfo fetch has succeeded since startup
+func (c *NextcloudCollector) Ready() bool {
+	return c.fetchedOnce.Load()
+}
+
+// WarmUp fetches serverinfo every fetch interval until the first fetch
+// succeeds, so readiness does not depend on Prometheus scraping first
+func (c *NextcloudCollector) WarmUp(ctx context.Context) {
+	for {
+		if _, _, err := c.fetchDataCached(); err != nil {
+			log.Printf("Warmup fetch from %s failed: %v", c.instance.BaseURL, err)
+		}
+		if c.Ready() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.config.FetchInterval):
+		}
+	}
+}
+
+// restoreCache seeds the in-memory cache from the persisted cache file. Restored
+// responses keep their original fetch time, so a fresh fetch is still attempted
+// once the fetch interval has passed and they are only served if it fails.
+func (c *NextcloudCollector) restoreCache(f *cacheFile) {
+	entry := f.entry(c.instance.BaseURL)
+
+	c.cacheMu.Lock()
+	c.cacheFile = f
+	if entry.Status != nil {
+		c.cachedStatus = entry.Status
+		c.lastStatusFetch = entry.StatusTime
+	}
+	if entry.Data != nil {
+		c.cachedData = entry.Data
+		c.lastFetchTime = entry.DataTime
+	}
+	c.cacheMu.Unlock()
+
+	if entry.Data != nil {
+		log.Printf("Restored serverinfo data for %s from cache file (age %s)", c.instance.BaseURL, time.Since(entry.DataTime).Round(time.Second))
+	}
+}
+
+// persistCache writes the current cached responses to the cache file, if any
+func (c *NextcloudCollector) persistCache() {
+	c.cacheMu.RLock()
+	f := c.cacheFile
+	entry := cacheEntry{
+		Status:     c.cachedStatus,
+		StatusTime: c.lastStatusFetch,
+		Data:       c.cachedData,
+		DataTime:   c.lastFetchTime,
+	}
+	c.cacheMu.RUnlock()
+
+	if f != nil {
+		f.save(c.instance.BaseURL, entry)
+	}
+}
+
+// fetchStatusCached returns cached status if within fetch interval, otherwise fetches fresh data.
+// fetched reports whether this call got a fresh answer from status.php.
+func (c *NextcloudCollector) fetchStatusCached() (status *StatusResponse, fetched bool, err error) {
 	c.cacheMu.RLock()
 	if c.cachedStatus != nil && time.Since(c.lastStatusFetch) < c.config.FetchInterval {
 		status := c.cachedStatus
+		age := time.Since(c.lastStatusFetch)
 		c.cacheMu.RUnlock()
-		return status, nil
+		c.debugf("status: cache hit (age %s < interval %s)", age.Round(time.Millisecond), c.config.FetchInterval)
+		return status, false, nil
 	}
 	c.cacheMu.RUnlock()
+	c.debugf("status: cache miss, fetching")
 
 	// Need to fetch fresh data
-	status, err := c.fetchStatus()
+	status, err = c.fetchStatus()
+	c.cacheMu.Lock()
+	c.lastStatusErr = err
+	c.cacheMu.Unlock()
 	if err != nil {
 		// If fetch fails but we have cached data, return cached data
 		c.cacheMu.RLock()
@@ -168,32 +682,46 @@ func (c *NextcloudCollector) fetchStatusCached() (*StatusResponse, error) {
 			cachedStatus := c.cachedStatus
 			c.cacheMu.RUnlock()
 			log.Printf("Using cached status data due to fetch error: %v", err)
-			return cachedStatus, nil
+			return cachedStatus, false, nil
 		}
 		c.cacheMu.RUnlock()
-		return nil, err
+		return nil, false, err
 	}
 
 	c.cacheMu.Lock()
 	c.cachedStatus = status
 	c.lastStatusFetch = time.Now()
 	c.cacheMu.Unlock()
+	c.persistCache()
 
-	return status, nil
+	return status, true, nil
 }
 
-// fetchDataCached returns cached data if within fetch interval, otherwise fetches fresh data
-func (c *NextcloudCollector) fetchDataCached() (*OCSResponse, error) {
+// fetchDataCached returns cached data if within fetch interval, otherwise fetches fresh data.
+// fetched reports whether this call requested serverinfo from upstream, even
+// if the request failed.
+func (c *NextcloudCollector) fetchDataCached() (data *OCSResponse, fetched bool, err error) {
 	c.cacheMu.RLock()
 	if c.cachedData != nil && time.Since(c.lastFetchTime) < c.config.FetchInterval {
 		data := c.cachedData
+		age := time.Since(c.lastFetchTime)
 		c.cacheMu.RUnlock()
-		return data, nil
+		c.debugf("serverinfo: cache hit (age %s < interval %s)", age.Round(time.Millisecond), c.config.FetchInterval)
+		return data, false, nil
 	}
 	c.cacheMu.RUnlock()
+	c.debugf("serverinfo: cache miss, fetching")
 
 	// Need to fetch fresh data
-	data, err := c.fetchData()
+	data, err = c.fetchData()
+	if result, ok := authResult(err); ok {
+		c.authResults[result].Add(1)
+	}
+	c.cacheMu.Lock()
+	c.lastDataErr = err
+	c.lastFetchTimedOut = err != nil && isTimeout(err)
+	c.lastFetchEmptyData = err != nil && failureReason(err) == reasonEmptyData
+	c.cacheMu.Unlock()
 	if err != nil {
 		// If fetch fails but we have cached data, return cached data
 		c.cacheMu.RLock()
@@ -201,22 +729,93 @@ func (c *NextcloudCollector) fetchDataCached() (*OCSResponse, error) {
 			cachedData := c.cachedData
 			c.cacheMu.RUnlock()
 			log.Printf("Using cached serverinfo data due to fetch error: %v", err)
-			return cachedData, nil
+			return cachedData, true, nil
 		}
 		c.cacheMu.RUnlock()
-		return nil, err
+		return nil, true, err
+	}
+
+	// Newer versions moved active users to a dedicated endpoint
+	if c.config.ActiveUsersFallback && data.OCS.Data.ActiveUsers == (ActiveUsersData{}) {
+		activeUsers, err := c.fetchActiveUsers()
+		if err != nil {
+			log.Printf("Error fetching active users: %v", err)
+		} else if activeUsers != nil {
+			data.OCS.Data.ActiveUsers = *activeUsers
+		}
 	}
 
 	c.cacheMu.Lock()
+	if c.cachedData != nil {
+		c.usersAdded = nonNegativeDelta(data.OCS.Data.Nextcloud.Storage.NumUsers, c.cachedData.OCS.Data.Nextcloud.Storage.NumUsers)
+		c.sharesCreated = nonNegativeDelta(data.OCS.Data.Nextcloud.Shares.NumShares, c.cachedData.OCS.Data.Nextcloud.Shares.NumShares)
+		c.activeUsersDailyGrowth = nonNegativeDelta(data.OCS.Data.ActiveUsers.Last24Hours, c.cachedData.OCS.Data.ActiveUsers.Last24Hours)
+	}
+	if c.config.CPULoadEMAAlpha > 0 {
+		c.updateCPULoadEMA(data.OCS.Data.Nextcloud.System.CPULoad)
+	}
 	c.cachedData = data
 	c.lastFetchTime = time.Now()
 	c.cacheMu.Unlock()
+	c.fetchedOnce.Store(true)
+	c.persistCache()
 
-	return data, nil
+	return data, true, nil
+}
+
+// cachedServerinfo returns the most recently fetched serverinfo data without
+// fetching, or nil when none has been fetched yet
+func (c *NextcloudCollector) cachedServerinfo() *OCSResponse {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+	return c.cachedData
+}
+
+// fetchCapabilitiesCached returns cached capabilities if within fetch interval, otherwise fetches fresh data
+func (c *NextcloudCollector) fetchCapabilitiesCached() (map[string]bool, error) {
+	c.cacheMu.RLock()
+	if c.cachedCapabilities != nil && time.Since(c.lastCapabilitiesFetch) < c.config.FetchInterval {
+		capabilities := c.cachedCapabilities
+		c.cacheMu.RUnlock()
+		return capabilities, nil
+	}
+	c.cacheMu.RUnlock()
+
+	capabilities, err := c.fetchCapabilities()
+	if err != nil {
+		c.cacheMu.RLock()
+		if c.cachedCapabilities != nil {
+			cachedCapabilities := c.cachedCapabilities
+			c.cacheMu.RUnlock()
+			log.Printf("Using cached capabilities due to fetch error: %v", err)
+			return cachedCapabilities, nil
+		}
+		c.cacheMu.RUnlock()
+		return nil, err
+	}
+
+	c.cacheMu.Lock()
+	c.cachedCapabilities = capabilities
+	c.lastCapabilitiesFetch = time.Now()
+	c.cacheMu.Unlock()
+
+	return capabilities, nil
+}
+
+// recordTLSState remembers the upstream certificate expiry from an HTTPS
+// response. Only responses from -url are recorded, so a -status-url on another
+// host cannot make the reported certificate alternate between fetches.
+func (c *NextcloudCollector) recordTLSState(resp *http.Response) {
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return
+	}
+	c.cacheMu.Lock()
+	c.tlsNotAfter = resp.TLS.PeerCertificates[0].NotAfter
+	c.cacheMu.Unlock()
 }
 
 func (c *NextcloudCollector) fetchStatus() (*StatusResponse, error) {
-	url := c.config.BaseURL + "/status.php"
+	url := c.instance.StatusURL + "/status.php"
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
@@ -224,70 +823,364 @@ func (c *NextcloudCollector) fetchStatus() (*StatusResponse, error) {
 
 	req.Header.Set("Accept", "application/json")
 
+	req = c.withTrace(req, "status")
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("executing request: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("rate limited (429): too many requests")
+		return nil, newScrapeError(reasonRateLimited, fmt.Errorf("rate limited (429): too many requests"))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if !isSuccessStatus(resp.StatusCode) {
+		return nil, newHTTPStatusError(resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("reading response body: %w", err))
 	}
+	c.bytesRead["status"].Add(uint64(len(body)))
 
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	// With -strict-decode an unknown field is counted but does not fail the
+	// fetch: the decoder records it and still decodes the remaining fields
 	var data StatusResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, fmt.Errorf("parsing JSON: %w", err)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if c.config.StrictDecode {
+		decoder.DisallowUnknownFields()
+	}
+	err = decoder.Decode(&data)
+	if err != nil && c.config.StrictDecode && isUnknownFieldError(err) {
+		c.unknownFields.Add(1)
+		c.debugf("status: %v", err)
+		err = nil
+	}
+	if err != nil {
+		return nil, newScrapeError(reasonParse, fmt.Errorf("parsing JSON: %w", err))
 	}
 
 	return &data, nil
 }
 
 func (c *NextcloudCollector) fetchData() (*OCSResponse, error) {
-	url := c.config.BaseURL + "/ocs/v2.php/apps/serverinfo/api/v1/info?format=json&skipApps=false&skipUpdate=false"
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.instance.BaseURL + "/ocs/v2.php/apps/serverinfo/api/v1/info?format=json&skipApps=false&skipUpdate=false"
+
+	// POST goes out with an empty body and Content-Length: 0
+	var reqBody io.Reader
+	if c.config.ServerinfoMethod == "POST" {
+		reqBody = http.NoBody
+	}
+	req, err := http.NewRequest(c.config.ServerinfoMethod, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("NC-Token", c.config.Token)
-	req.Header.Set("Accept", "application/json")
+	c.setOCSHeaders(req)
 
+	req = c.withTrace(req, "serverinfo")
 	resp, err := c.client.Do(req)
+	c.cacheMu.Lock()
+	c.serverinfoStatusCode = 0
+	if err == nil {
+		c.serverinfoStatusCode = resp.StatusCode
+	}
+	c.cacheMu.Unlock()
 	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("executing request: %w", err))
 	}
 	defer resp.Body.Close()
+	c.recordTLSState(resp)
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("rate limited (429): too many requests")
+		return nil, newScrapeError(reasonRateLimited, fmt.Errorf("rate limited (429): too many requests"))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if !isSuccessStatus(resp.StatusCode) {
+		return nil, newHTTPStatusError(resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("reading response body: %w", err))
 	}
+	c.bytesRead["serverinfo"].Add(uint64(len(body)))
 
 	var data OCSResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, fmt.Errorf("parsing JSON: %w", err)
+
+	// Some reverse proxies strip format=json, in which case OCS answers in XML
+	if isXMLContentType(resp.Header.Get("Content-Type")) {
+		if err := xml.Unmarshal(body, &data.OCS); err != nil {
+			return nil, newScrapeError(reasonParse, fmt.Errorf("parsing XML: %w", err))
+		}
+	} else {
+		if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(body, &data); err != nil {
+			if isEmptyDataArray(body) {
+				return nil, newScrapeError(reasonEmptyData, fmt.Errorf("serverinfo returned an empty data array"))
+			}
+			return nil, newScrapeError(reasonParse, fmt.Errorf("parsing JSON: %w", err))
+		}
+
+		// encoding/json matches keys case-insensitively; count the non-canonical ones
+		// for compat debugging (this parses the body a second time)
+		if variants := casingVariants(body); len(variants) > 0 {
+			c.casingVariants.Add(uint64(len(variants)))
+			c.debugf("serverinfo: non-canonical key casing: %s", strings.Join(variants, ", "))
+		}
+	}
+
+	// The version is missing when sub-queries are skipped; fall back to the response headers
+	if data.OCS.Data.Nextcloud.System.Version == "" {
+		data.OCS.Data.Nextcloud.System.Version = versionFromHeaders(resp.Header)
 	}
 
 	return &data, nil
 }
 
+// isEmptyDataArray reports whether an OCS body carries "data": [] instead of an
+// object, which Nextcloud returns under some error conditions
+func isEmptyDataArray(body []byte) bool {
+	var probe struct {
+		OCS struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"ocs"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(probe.OCS.Data), []byte("["))
+}
+
+// setOCSHeaders sets the authentication and content negotiation headers of an OCS request
+func (c *NextcloudCollector) setOCSHeaders(req *http.Request) {
+	req.Header.Set("NC-Token", c.instance.Token)
+	req.Header.Set("Accept", "application/json")
+	if c.config.OCSAPIRequestHeader {
+		req.Header.Set("OCS-APIRequest", "true")
+	}
+}
+
+// fetchActiveUsers returns the active user counts from the dedicated serverinfo
+// endpoint. A 404 yields nil without an error.
+func (c *NextcloudCollector) fetchActiveUsers() (*ActiveUsersData, error) {
+	url := c.instance.BaseURL + "/ocs/v2.php/apps/serverinfo/api/v1/activeUsers?format=json"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	c.setOCSHeaders(req)
+
+	req = c.withTrace(req, "activeUsers")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("executing request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		c.debugf("activeUsers: endpoint not found, skipping")
+		return nil, nil
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newScrapeError(reasonRateLimited, fmt.Errorf("rate limited (429): too many requests"))
+	}
+
+	if !isSuccessStatus(resp.StatusCode) {
+		return nil, newHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("reading response body: %w", err))
+	}
+	c.bytesRead["activeUsers"].Add(uint64(len(body)))
+
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	var data ActiveUsersResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, newScrapeError(reasonParse, fmt.Errorf("parsing JSON: %w", err))
+	}
+	return data.activeUsers(), nil
+}
+
+// versionFromHeaders returns the Nextcloud version advertised in response headers, or ""
+func versionFromHeaders(header http.Header) string {
+	return strings.TrimSpace(header.Get("X-Nextcloud-Version"))
+}
+
+// fetchCapabilities returns the boolean capabilities keyed by dotted path
+// (e.g. "files_sharing.public.enabled"). A 404 yields no capabilities.
+func (c *NextcloudCollector) fetchCapabilities() (map[string]bool, error) {
+	url := c.instance.BaseURL + "/ocs/v2.php/cloud/capabilities?format=json"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	c.setOCSHeaders(req)
+
+	req = c.withTrace(req, "capabilities")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("executing request: %w", err))
+	}
+	defer resp.Body.Close()
+	c.recordTLSState(resp)
+
+	if resp.StatusCode == http.StatusNotFound {
+		c.debugf("capabilities: endpoint not found, skipping")
+		return map[string]bool{}, nil
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newScrapeError(reasonRateLimited, fmt.Errorf("rate limited (429): too many requests"))
+	}
+
+	if !isSuccessStatus(resp.StatusCode) {
+		return nil, newHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("reading response body: %w", err))
+	}
+	c.bytesRead["capabilities"].Add(uint64(len(body)))
+
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	var data CapabilitiesResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, newScrapeError(reasonParse, fmt.Errorf("parsing JSON: %w", err))
+	}
+
+	// Only the configured capabilities are kept, so the number of series does
+	// not grow with every installed app
+	all := map[string]bool{}
+	flattenCapabilities("", data.OCS.Data.Capabilities, all)
+	capabilities := map[string]bool{}
+	for _, name := range c.config.Capabilities {
+		if enabled, ok := all[name]; ok {
+			capabilities[name] = enabled
+		}
+	}
+	return capabilities, nil
+}
+
+// flattenCapabilities collects the boolean leaves of a capabilities tree into out, keyed by dotted path
+func flattenCapabilities(prefix string, node map[string]any, out map[string]bool) {
+	for key, value := range node {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case bool:
+			out[name] = v
+		case map[string]any:
+			flattenCapabilities(name, v, out)
+		}
+	}
+}
+
+// baseURLScheme returns the scheme of a base URL, or "" when it cannot be parsed
+func baseURLScheme(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+// baseURLHost returns the host[:port] of a base URL without scheme, path or
+// userinfo, or "" when it cannot be parsed
+func baseURLHost(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// isSuccessStatus reports whether code is a 2xx status. Some proxies answer
+// serverinfo with 203 or 206 and a valid body.
+func isSuccessStatus(code int) bool {
+	return code >= 200 && code < 300
+}
+
+// mediaType returns the lower-cased media type of a Content-Type header without parameters
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(strings.ToLower(mt))
+}
+
+// isXMLContentType reports whether a Content-Type header denotes an XML body
+func isXMLContentType(contentType string) bool {
+	mt := mediaType(contentType)
+	return mt == "text/xml" || mt == "application/xml"
+}
+
+// checkJSONContentType rejects bodies that are clearly not JSON, such as the
+// HTML error pages served by WAFs and reverse proxies with a 200 status.
+// A missing Content-Type is accepted.
+func checkJSONContentType(contentType string) error {
+	mt := mediaType(contentType)
+	if mt == "" || mt == "application/json" || strings.HasSuffix(mt, "+json") {
+		return nil
+	}
+	return newScrapeError(reasonProxy, fmt.Errorf("unexpected content-type: %s", mt))
+}
+
+// unixSeconds normalizes a Unix timestamp to seconds. PHP reports seconds, but
+// values too large to be a plausible seconds timestamp are treated as milliseconds.
+func unixSeconds(timestamp float64) float64 {
+	const maxPlausibleSeconds = 1e11 // year ~5138
+	if timestamp > maxPlausibleSeconds {
+		return timestamp / 1000
+	}
+	return timestamp
+}
+
+// cacheBackend returns the lower-cased short name of a memcache class, e.g. "redis" for \OC\Memcache\Redis
+func cacheBackend(class string) string {
+	class = strings.TrimSpace(class)
+	if i := strings.LastIndex(class, "\\"); i >= 0 {
+		class = class[i+1:]
+	}
+	return strings.ToLower(class)
+}
+
+// ratio returns numerator / denominator, or 0 when the denominator is 0
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// nonNegativeDelta returns current - previous, or 0 when the value went down (treated as a reset)
+func nonNegativeDelta(current, previous int) int {
+	if current < previous {
+		return 0
+	}
+	return current - previous
+}
+
 func boolToFloat(b bool) float64 {
 	if b {
 		return 1
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Upstream paths served by fakeNextcloud
+const (
+	statusPath      = "/status.php"
+	serverinfoPath  = "/ocs/v2.php/apps/serverinfo/api/v1/info"
+	activeUsersPath = "/ocs/v2.php/apps/serverinfo/api/v1/activeUsers"
+)
+
+// fakeResponse is the answer of fakeNextcloud for one path
+type fakeResponse struct {
+	// fixture is a file in testdata/ sent as the body
+	fixture     string
+	contentType string
+	statusCode  int
+}
+
+// fakeNextcloud is an upstream serving testdata fixtures by URL path. It
+// records the requests it receives.
+type fakeNextcloud struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+// newFakeNextcloud starts an upstream answering status.php from
+// testdata/status.json, serverinfo from the given fixture, and routes for
+// further paths. Unknown paths answer 404.
+func newFakeNextcloud(t *testing.T, serverinfo string, routes map[string]fakeResponse) *fakeNextcloud {
+	t.Helper()
+	all := map[string]fakeResponse{
+		statusPath:     {fixture: "status.json"},
+		serverinfoPath: {fixture: serverinfo},
+	}
+	for path, response := range routes {
+		all[path] = response
+	}
+
+	f := &fakeNextcloud{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Clone(r.Context()))
+		f.mu.Unlock()
+
+		response, ok := all[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", response.fixture))
+		if err != nil {
+			t.Errorf("reading fixture: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		contentType := response.contentType
+		if contentType == "" {
+			contentType = "application/json; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		if response.statusCode != 0 {
+			w.WriteHeader(response.statusCode)
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// requestsTo returns the recorded requests for path
+func (f *fakeNextcloud) requestsTo(path string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []*http.Request
+	for _, r := range f.requests {
+		if r.URL.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// testConfig returns the configuration parseConfig yields without flags, for
+// the given instances
+func testConfig(instances ...Instance) *Config {
+	return &Config{
+		Instances:           instances,
+		ListenAddr:          DefaultListenAddr,
+		FetchInterval:       DefaultFetchInterval,
+		Timeout:             DefaultTimeout,
+		InfoMetricType:      DefaultInfoMetricType,
+		LogLevel:            DefaultLogLevel,
+		ActiveUserPeriods:   activeUserPeriods,
+		CacheMaxAge:         DefaultCacheMaxAge,
+		ServerinfoMethod:    DefaultServerinfoMethod,
+		PushJob:             DefaultPushJob,
+		MaxConnections:      DefaultMaxConnections,
+		OCSAPIRequestHeader: true,
+		ExtraEndpoints:      map[string]bool{},
+		Capabilities:        defaultCapabilities,
+	}
+}
+
+// newTestCollector returns a collector for upstream, with the configuration
+// adjusted by configure when it is not nil
+func newTestCollector(upstream *fakeNextcloud, configure func(*Config)) *NextcloudCollector {
+	instance := Instance{BaseURL: upstream.URL, Token: "token", StatusURL: upstream.URL}
+	config := testConfig(instance)
+	if configure != nil {
+		configure(config)
+	}
+	return NewNextcloudCollector(config, instance)
+}
+
+// volatileMetrics change between runs (timestamps, the random test server port,
+// the current date) and are left out of golden files
+var volatileMetrics = map[string]bool{
+	"nextcloud_cache_valid_for_seconds":      true,
+	"nextcloud_exporter_config_hash":         true,
+	"nextcloud_exporter_scrape_time_seconds": true,
+	"nextcloud_exporter_target_info":         true,
+	"nextcloud_php_version_eol":              true,
+	"nextcloud_upstream_bytes_read_total":    true,
+}
+
+// compareGolden checks what c collects against testdata/<golden>. Without
+// names every metric except volatileMetrics is compared, so a series missing
+// from the golden file fails the test; with names only those are compared.
+func compareGolden(t *testing.T, c *NextcloudCollector, golden string, names ...string) {
+	t.Helper()
+	expected, err := os.ReadFile(filepath.Join("testdata", golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		for _, name := range c.metrics.names {
+			if !volatileMetrics[name] {
+				names = append(names, name)
+			}
+		}
+	}
+	if err := testutil.CollectAndCompare(c, bytes.NewReader(expected), names...); err != nil {
+		t.Errorf("%s: %v", golden, err)
+	}
+}
+
+func TestCollectGolden(t *testing.T) {
+	tests := []struct {
+		serverinfo string
+		golden     string
+	}{
+		{"serverinfo.json", "serverinfo.prom"},
+		{"serverinfo_null_cpuload.json", "serverinfo_null_cpuload.prom"},
+		{"serverinfo_string_mem.json", "serverinfo_string_mem.prom"},
+		{"serverinfo_humanized_db_size.json", "serverinfo_humanized_db_size.prom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverinfo, func(t *testing.T) {
+			upstream := newFakeNextcloud(t, tt.serverinfo, nil)
+			compareGolden(t, newTestCollector(upstream, nil), tt.golden)
+		})
+	}
+}
+
+// compareMetrics checks the named metrics c collects against the expected
+// exposition text
+func compareMetrics(t *testing.T, c prometheus.Collector, expected string, names ...string) {
+	t.Helper()
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+// mismatchedDesc returns a descriptor for name that expects a label the
+// collector does not pass, so MustNewConstMetric panics when it is used
+func mismatchedDesc(name string) *prometheus.Desc {
+	return prometheus.NewDesc(name, "Descriptor with an extra label", []string{"unexpected"}, nil)
+}
+
+func TestCollectPanicStillEmitsScrapeSuccess(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+	c.metrics.FreeSpace = mismatchedDesc("nextcloud_system_freespace_bytes")
+
+	compareMetrics(t, c, `
+# HELP nextcloud_collect_panic_total Total number of panics recovered while building metrics
+# TYPE nextcloud_collect_panic_total counter
+nextcloud_collect_panic_total 1
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 0
+`, "nextcloud_scrape_success", "nextcloud_collect_panic_total")
+}
+
+func TestCollectFreeSpaceAsString(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_freespace_string.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_system_freespace_bytes Free disk space in bytes
+# TYPE nextcloud_system_freespace_bytes gauge
+nextcloud_system_freespace_bytes 1.23456789e+08
+`, "nextcloud_system_freespace_bytes")
+}
+
+func TestCollectPanicInStatusMetricsIsContained(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+	c.metrics.StatusInstalled = mismatchedDesc("nextcloud_status_installed")
+
+	// The status section is skipped; serverinfo metrics are still reported
+	compareMetrics(t, c, `
+# HELP nextcloud_collect_panic_total Total number of panics recovered while building metrics
+# TYPE nextcloud_collect_panic_total counter
+nextcloud_collect_panic_total 1
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 1
+# HELP nextcloud_users_total Total number of users
+# TYPE nextcloud_users_total gauge
+nextcloud_users_total 10
+`, "nextcloud_collect_panic_total", "nextcloud_scrape_success", "nextcloud_users_total")
+}
+
+func TestCollectSkipsNonFiniteCPULoad(t *testing.T) {
+	upstream := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "serverinfo_nan_cpuload.xml", contentType: "text/xml; charset=UTF-8"},
+	})
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_invalid_metric_values_total Total number of non-finite upstream values skipped instead of being emitted
+# TYPE nextcloud_invalid_metric_values_total counter
+nextcloud_invalid_metric_values_total{metric="cpuload"} 2
+# HELP nextcloud_system_cpuload CPU load average
+# TYPE nextcloud_system_cpuload gauge
+nextcloud_system_cpuload{interval="5m"} 0.4
+`, "nextcloud_invalid_metric_values_total", "nextcloud_system_cpuload")
+}
+
+func TestCollectNaNMetricsLeavesOutCPULoad(t *testing.T) {
+	upstream := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "blocked.html", contentType: "text/html"},
+	})
+	c := newTestCollector(upstream, func(config *Config) {
+		config.EmitZerosOnFailure = true
+	})
+	compareMetrics(t, c, "", "nextcloud_system_cpuload")
+}
+
+func TestCollectXMLServerinfo(t *testing.T) {
+	// Proxies that strip format=json get XML; it must yield the same metrics
+	upstream := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "serverinfo.xml", contentType: "text/xml; charset=UTF-8"},
+	})
+	compareGolden(t, newTestCollector(upstream, nil), "serverinfo.prom")
+}
+
+func TestCollectHTMLErrorPage(t *testing.T) {
+	// A WAF block page answered with 200 is a proxy error, not a parse error
+	upstream := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "blocked.html", contentType: "text/html; charset=UTF-8"},
+	})
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_scrape_error Reason the serverinfo fetch failed (network, rate_limited, http_status, proxy, parse, empty_data, unknown), only present on failure
+# TYPE nextcloud_scrape_error gauge
+nextcloud_scrape_error{reason="proxy"} 1
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 0
+`, "nextcloud_scrape_error", "nextcloud_scrape_success")
+}
+
+func TestServerinfoPOST(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, func(config *Config) {
+		config.ServerinfoMethod = http.MethodPost
+	})
+	compareGolden(t, c, "serverinfo.prom")
+
+	requests := upstream.requestsTo(serverinfoPath)
+	if len(requests) != 1 {
+		t.Fatalf("got %d serverinfo requests, want 1", len(requests))
+	}
+	if r := requests[0]; r.Method != http.MethodPost || r.ContentLength != 0 {
+		t.Errorf("got %s with Content-Length %d, want POST with an empty body", r.Method, r.ContentLength)
+	}
+}
+
+func TestServerinfoNon200Success(t *testing.T) {
+	upstream := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "serverinfo.json", statusCode: http.StatusNonAuthoritativeInfo},
+	})
+	compareGolden(t, newTestCollector(upstream, nil), "serverinfo.prom")
+}
+
+func TestStatusUnknownField(t *testing.T) {
+	tests := []struct {
+		strict   bool
+		expected string
+	}{
+		{false, `
+# HELP nextcloud_status_installed Nextcloud installation status (1 = installed, 0 = not installed)
+# TYPE nextcloud_status_installed gauge
+nextcloud_status_installed 1
+`},
+		{true, `
+# HELP nextcloud_status_installed Nextcloud installation status (1 = installed, 0 = not installed)
+# TYPE nextcloud_status_installed gauge
+nextcloud_status_installed 1
+# HELP nextcloud_unknown_fields_total Number of status.php responses containing fields the exporter does not know
+# TYPE nextcloud_unknown_fields_total counter
+nextcloud_unknown_fields_total 1
+`},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("strict=%t", tt.strict), func(t *testing.T) {
+			upstream := newFakeNextcloud(t, "serverinfo.json", map[string]fakeResponse{
+				statusPath: {fixture: "status_extra_field.json"},
+			})
+			c := newTestCollector(upstream, func(config *Config) {
+				config.StrictDecode = tt.strict
+			})
+			compareMetrics(t, c, tt.expected, "nextcloud_status_installed", "nextcloud_unknown_fields_total")
+		})
+	}
+}
+
+func TestOCSAPIRequestHeader(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+			c := newTestCollector(upstream, func(config *Config) {
+				config.OCSAPIRequestHeader = enabled
+			})
+			testutil.CollectAndCount(c)
+
+			requests := upstream.requestsTo(serverinfoPath)
+			if len(requests) != 1 {
+				t.Fatalf("got %d serverinfo requests, want 1", len(requests))
+			}
+			want := ""
+			if enabled {
+				want = "true"
+			}
+			if got := requests[0].Header.Get("OCS-APIRequest"); got != want {
+				t.Errorf("got OCS-APIRequest %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCollectCasingVariants(t *testing.T) {
+	// Keys are matched case-insensitively and the variants are counted. The app
+	// id "Version" is not a field and must not be counted.
+	upstream := newFakeNextcloud(t, "serverinfo_casing.json", nil)
+	c := newTestCollector(upstream, nil)
+	compareMetrics(t, c, `
+# HELP nextcloud_field_casing_variant_total Number of serverinfo fields matched despite a non-canonical key casing
+# TYPE nextcloud_field_casing_variant_total counter
+nextcloud_field_casing_variant_total 2
+# HELP nextcloud_system_freespace_bytes Free disk space in bytes
+# TYPE nextcloud_system_freespace_bytes gauge
+nextcloud_system_freespace_bytes 1.23456789e+08
+# HELP nextcloud_users_total Total number of users
+# TYPE nextcloud_users_total gauge
+nextcloud_users_total 10
+`, "nextcloud_field_casing_variant_total", "nextcloud_system_freespace_bytes", "nextcloud_users_total")
+}
+
+func TestMultiInstanceScrapeSuccess(t *testing.T) {
+	healthy := newFakeNextcloud(t, "serverinfo.json", nil)
+	failing := newFakeNextcloud(t, "", map[string]fakeResponse{
+		serverinfoPath: {fixture: "blocked.html", contentType: "text/html", statusCode: http.StatusInternalServerError},
+	})
+	instances := []Instance{
+		{BaseURL: healthy.URL, Token: "token", StatusURL: healthy.URL},
+		{BaseURL: failing.URL, Token: "token", StatusURL: failing.URL},
+	}
+	config := testConfig(instances...)
+
+	// Registered as in main: each collector wrapped with its instance label
+	registry := prometheus.NewPedanticRegistry()
+	for _, instance := range instances {
+		labels := prometheus.Labels{"instance": instance.BaseURL}
+		prometheus.WrapRegistererWith(labels, registry).MustRegister(NewNextcloudCollector(config, instance))
+	}
+
+	expected := fmt.Sprintf(`
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success{instance=%q} 1
+nextcloud_scrape_success{instance=%q} 0
+`, healthy.URL, failing.URL)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nextcloud_scrape_success"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnixSeconds(t *testing.T) {
+	tests := []struct {
+		timestamp float64
+		want      float64
+	}{
+		{0, 0},
+		{1700000000, 1700000000},
+		{1700000000123, 1700000000.123},
+		{1e11, 1e11},
+	}
+	for _, tt := range tests {
+		if got := unixSeconds(tt.timestamp); got != tt.want {
+			t.Errorf("unixSeconds(%v) = %v, want %v", tt.timestamp, got, tt.want)
+		}
+	}
+}
+
+func TestCollectMillisecondTimestamp(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_restart_ms.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_php_opcache_last_restart_seconds Time of the last PHP OPcache restart as a Unix timestamp in seconds
+# TYPE nextcloud_php_opcache_last_restart_seconds gauge
+nextcloud_php_opcache_last_restart_seconds 1.700000000123e+09
+`, "nextcloud_php_opcache_last_restart_seconds")
+}
+
+func TestCollectSecurityUpdates(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_security_updates.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_apps_security_updates_available_total Number of app updates available that are security updates
+# TYPE nextcloud_apps_security_updates_available_total gauge
+nextcloud_apps_security_updates_available_total 1
+`, "nextcloud_apps_security_updates_available_total")
+}
+
+func TestCollectFilesByStorage(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_files_by_storage.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_files_by_storage Number of files by storage type
+# TYPE nextcloud_files_by_storage gauge
+nextcloud_files_by_storage{type="home"} 900
+nextcloud_files_by_storage{type="local"} 60
+nextcloud_files_by_storage{type="other"} 40
+`, "nextcloud_files_by_storage")
+}
+
+func TestCollectAppInfo(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_app_list.json", nil)
+	c := newTestCollector(upstream, func(config *Config) {
+		config.AppInfoLimit = 2
+	})
+	compareMetrics(t, c, `
+# HELP nextcloud_app_info Installed Nextcloud app information
+# TYPE nextcloud_app_info gauge
+nextcloud_app_info{app="activity",version="2.20.0"} 1
+nextcloud_app_info{app="calendar",version="4.6.1"} 1
+`, "nextcloud_app_info")
+}
+
+func TestCollectStoragesExternal(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_storages_external.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_storages_external_total Number of external storage mounts (S3, SMB, etc.)
+# TYPE nextcloud_storages_external_total gauge
+nextcloud_storages_external_total 2
+`, "nextcloud_storages_external_total")
+}
+
+func TestCollectDatabaseWarnings(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_db_warnings.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_database_missing_indices Number of missing database indices (occ db:add-missing-indices)
+# TYPE nextcloud_database_missing_indices gauge
+nextcloud_database_missing_indices 3
+# HELP nextcloud_database_pending_bigint_conversions Number of columns pending conversion to bigint (occ db:convert-filecache-bigint)
+# TYPE nextcloud_database_pending_bigint_conversions gauge
+nextcloud_database_pending_bigint_conversions 1
+`, "nextcloud_database_missing_indices", "nextcloud_database_pending_bigint_conversions")
+}
+
+func TestActiveUsersFallback(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_no_active_users.json", map[string]fakeResponse{
+		activeUsersPath: {fixture: "active_users.json"},
+	})
+	c := newTestCollector(upstream, func(config *Config) {
+		config.ActiveUsersFallback = true
+	})
+	compareMetrics(t, c, `
+# HELP nextcloud_active_users Number of active users
+# TYPE nextcloud_active_users gauge
+nextcloud_active_users{period="1hour"} 4
+nextcloud_active_users{period="1month"} 11
+nextcloud_active_users{period="1year"} 14
+nextcloud_active_users{period="24hours"} 6
+nextcloud_active_users{period="3months"} 12
+nextcloud_active_users{period="5min"} 3
+nextcloud_active_users{period="6months"} 13
+nextcloud_active_users{period="7days"} 8
+`, "nextcloud_active_users")
+}
+
+func TestActiveUsersFallbackNotFound(t *testing.T) {
+	// Without the dedicated endpoint the scrape still succeeds
+	upstream := newFakeNextcloud(t, "serverinfo_no_active_users.json", nil)
+	c := newTestCollector(upstream, func(config *Config) {
+		config.ActiveUsersFallback = true
+	})
+	compareMetrics(t, c, `
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 1
+`, "nextcloud_scrape_success")
+	if len(upstream.requestsTo(activeUsersPath)) != 1 {
+		t.Error("activeUsers endpoint not requested")
+	}
+}
+
+func TestCollectUpdatesByType(t *testing.T) {
+	tests := []struct {
+		serverinfo string
+		expected   string
+	}{
+		// security is only present when the version reports it
+		{"serverinfo.json", `
+# HELP nextcloud_updates_available Number of available updates by type (core is 0/1)
+# TYPE nextcloud_updates_available gauge
+nextcloud_updates_available{type="app"} 2
+nextcloud_updates_available{type="core"} 1
+`},
+		{"serverinfo_security_updates.json", `
+# HELP nextcloud_updates_available Number of available updates by type (core is 0/1)
+# TYPE nextcloud_updates_available gauge
+nextcloud_updates_available{type="app"} 2
+nextcloud_updates_available{type="core"} 1
+nextcloud_updates_available{type="security"} 1
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverinfo, func(t *testing.T) {
+			upstream := newFakeNextcloud(t, tt.serverinfo, nil)
+			compareMetrics(t, newTestCollector(upstream, nil), tt.expected, "nextcloud_updates_available")
+		})
+	}
+}
+
+func TestCollectCacheBackends(t *testing.T) {
+	// The locking cache is not reported by this fixture and is skipped
+	upstream := newFakeNextcloud(t, "serverinfo_memcache.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_cache_backend_info Configured memcache backend by cache type
+# TYPE nextcloud_cache_backend_info gauge
+nextcloud_cache_backend_info{backend="apcu",type="local"} 1
+nextcloud_cache_backend_info{backend="redis",type="distributed"} 1
+`, "nextcloud_cache_backend_info")
+}
+
+func TestCollectDatabaseSizeByType(t *testing.T) {
+	tests := []struct {
+		serverinfo string
+		expected   string
+	}{
+		// MySQL reports a numeric string
+		{"serverinfo.json", `
+# HELP nextcloud_database_size_available Whether the database size was reported and parseable (0/1)
+# TYPE nextcloud_database_size_available gauge
+nextcloud_database_size_available 1
+# HELP nextcloud_database_size_bytes Database size in bytes
+# TYPE nextcloud_database_size_bytes gauge
+nextcloud_database_size_bytes 1.2345678e+07
+`},
+		// PostgreSQL reports a number
+		{"serverinfo_db_pgsql.json", `
+# HELP nextcloud_database_size_available Whether the database size was reported and parseable (0/1)
+# TYPE nextcloud_database_size_available gauge
+nextcloud_database_size_available 1
+# HELP nextcloud_database_size_bytes Database size in bytes
+# TYPE nextcloud_database_size_bytes gauge
+nextcloud_database_size_bytes 2.048e+06
+`},
+		// SQLite may report an empty string
+		{"serverinfo_db_sqlite.json", `
+# HELP nextcloud_database_size_available Whether the database size was reported and parseable (0/1)
+# TYPE nextcloud_database_size_available gauge
+nextcloud_database_size_available 0
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverinfo, func(t *testing.T) {
+			upstream := newFakeNextcloud(t, tt.serverinfo, nil)
+			compareMetrics(t, newTestCollector(upstream, nil), tt.expected,
+				"nextcloud_database_size_available", "nextcloud_database_size_bytes")
+		})
+	}
+}
+
+func TestCollectEmptyDataArray(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo_empty_data.json", nil)
+	compareMetrics(t, newTestCollector(upstream, nil), `
+# HELP nextcloud_scrape_error Reason the serverinfo fetch failed (network, rate_limited, http_status, proxy, parse, empty_data, unknown), only present on failure
+# TYPE nextcloud_scrape_error gauge
+nextcloud_scrape_error{reason="empty_data"} 1
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 0
+# HELP nextcloud_serverinfo_empty_data Whether the last serverinfo fetch returned an empty data array instead of an object
+# TYPE nextcloud_serverinfo_empty_data gauge
+nextcloud_serverinfo_empty_data 1
+`, "nextcloud_scrape_error", "nextcloud_scrape_success", "nextcloud_serverinfo_empty_data")
+}
+
+func TestMaintenanceConsistencyNeedsBothFetches(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+	compareMetrics(t, c, `
+# HELP nextcloud_maintenance_state_consistent Whether status.php and serverinfo agree on maintenance mode (1 = agree, 0 = disagree)
+# TYPE nextcloud_maintenance_state_consistent gauge
+nextcloud_maintenance_state_consistent 1
+`, "nextcloud_maintenance_state_consistent")
+
+	// Expire only the status answer: serverinfo is served from the cache, so
+	// its status code is not compared with the fresh status.php answer
+	c.cacheMu.Lock()
+	c.lastStatusFetch = time.Time{}
+	c.cacheMu.Unlock()
+	compareMetrics(t, c, "", "nextcloud_maintenance_state_consistent")
+	if n := len(upstream.requestsTo(statusPath)); n != 2 {
+		t.Errorf("got %d status.php requests, want 2", n)
+	}
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"hash/fnv"
 	"log"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,63 +28,799 @@ const (
 
 	// DefaultListenAddr is the default address to listen on
 	DefaultListenAddr = ":9205"
+
+	// DefaultInfoMetricType is the default value type for info-style metrics
+	DefaultInfoMetricType = "gauge"
+
+	// DefaultLogLevel is the default log verbosity
+	DefaultLogLevel = "info"
+
+	// DefaultCacheMaxAge is the default age beyond which persisted responses are not served
+	DefaultCacheMaxAge = time.Hour
+
+	// DefaultServerinfoMethod is the default HTTP method for serverinfo requests
+	DefaultServerinfoMethod = "GET"
+
+	// DefaultPushJob is the default job label for metrics pushed with -once
+	DefaultPushJob = "nextcloud_exporter"
+
+	// DefaultMaxConnections is the default limit of concurrent connections per listener
+	DefaultMaxConnections = 100
 )
 
+// allowMockModeEnv must be set to 1 alongside -mock-mode, so a stray flag cannot relax validation in production
+const allowMockModeEnv = "NEXTCLOUD_EXPORTER_ALLOW_MOCK_MODE"
+
+// Instance is a single Nextcloud server to scrape
+type Instance struct {
+	BaseURL string
+	Token   string
+
+	// StatusURL is the base URL for status.php; it equals BaseURL unless -status-url is set
+	StatusURL string
+}
+
 // Config holds all configuration for the exporter
 type Config struct {
-	BaseURL       string
-	Token         string
+	Instances     []Instance
 	ListenAddr    string
 	FetchInterval time.Duration
 	Timeout       time.Duration
+
+	// HealthListenAddr serves the health endpoints on a separate listener when set
+	HealthListenAddr string
+
+	// DisableLandingPage answers / with 404 instead of the HTML landing page
+	DisableLandingPage bool
+
+	// InfoMetricType is the value type used for info-style metrics ("gauge" or "untyped")
+	InfoMetricType string
+
+	// LogLevel is the log verbosity ("info" or "debug")
+	LogLevel string
+
+	// FreeSpaceWarnBytes is the free space threshold for nextcloud_system_freespace_below_threshold (0 = disabled)
+	FreeSpaceWarnBytes int64
+
+	// ScrapeCapabilities enables fetching /ocs/v2.php/cloud/capabilities
+	ScrapeCapabilities bool
+
+	// Aggregate additionally emits fleet-wide totals in multi-instance mode
+	Aggregate bool
+
+	// EmitZerosOnFailure emits serverinfo metrics as NaN when a fetch fails and nothing is cached
+	EmitZerosOnFailure bool
+
+	// ActiveUserPeriods are the nextcloud_active_users periods to emit
+	ActiveUserPeriods []string
+
+	// AppInfoLimit caps the number of nextcloud_app_info series (0 = disabled)
+	AppInfoLimit int
+
+	// CPULoadEMAAlpha is the smoothing factor for nextcloud_system_cpuload_smoothed (0 = disabled)
+	CPULoadEMAAlpha float64
+
+	// TraceRequests logs a latency breakdown of upstream requests at debug level
+	TraceRequests bool
+
+	// CacheFile persists the last successful responses across restarts when set
+	CacheFile string
+
+	// CacheMaxAge is the age beyond which responses from CacheFile are not served
+	CacheMaxAge time.Duration
+
+	// ServerinfoMethod is the HTTP method for serverinfo requests ("GET" or "POST")
+	ServerinfoMethod string
+
+	// WaitForFirstScrape makes /-/ready fail until every instance has been fetched successfully
+	WaitForFirstScrape bool
+
+	// ActiveUsersFallback fetches active users from the dedicated endpoint when serverinfo omits them
+	ActiveUsersFallback bool
+
+	// Once scrapes a single time, pushes to PushGateway and exits instead of serving HTTP
+	Once bool
+
+	// PushGateway is the Pushgateway URL metrics are pushed to with Once
+	PushGateway string
+
+	// PushJob is the job label of pushed metrics
+	PushJob string
+
+	// MaxConnections limits concurrent connections per listener; further connections wait
+	MaxConnections int
+
+	// TLSServerName overrides the hostname used for upstream TLS verification and SNI
+	TLSServerName string
+
+	// Resolver is the DNS server (host:port) used to resolve upstream hostnames; the system resolver is used when empty
+	Resolver string
+
+	// StrictDecode rejects unknown status.php fields first to detect schema additions, then decodes leniently
+	StrictDecode bool
+
+	// ExtraEndpoints are the enabled extra OCS endpoints, keyed by name
+	ExtraEndpoints map[string]bool
+
+	// LinkNoPasswordWarn is the link-share-without-password count above which nextcloud_shares_link_no_password_exceeds_threshold is 1 (0 = disabled)
+	LinkNoPasswordWarn int
+
+	// InsecureSkipVerify disables upstream TLS certificate verification
+	InsecureSkipVerify bool
+
+	// CACertFile is a PEM file with CA certificates to verify the upstream with; it overrides ca.crt from the credentials directory
+	CACertFile string
+
+	// MockMode relaxes URL validation and the token requirement for testing against mock servers; it requires NEXTCLOUD_EXPORTER_ALLOW_MOCK_MODE=1
+	MockMode bool
+
+	// OCSAPIRequestHeader sends OCS-APIRequest: true with OCS requests, which some setups require to skip the CSRF login redirect
+	OCSAPIRequestHeader bool
+
+	// SkipSuspiciousZeros skips the storage and free space metrics when num_users, num_files and freespace are all zero, which indicates a bad payload
+	SkipSuspiciousZeros bool
+
+	// InstancesFile is a JSON file listing the instances to scrape, as an alternative to the -url/-token lists
+	InstancesFile string
+
+	// ExtraEndpointsUser and ExtraEndpointsPassword are the Nextcloud login for extra endpoints that need user authentication
+	ExtraEndpointsUser     string
+	ExtraEndpointsPassword string
+
+	// Capabilities are the dotted capability paths exposed as nextcloud_capability, keeping its cardinality independent of the installed apps
+	Capabilities []string
+
+	// RootCAs overrides the system certificate pool for upstream TLS when set
+	RootCAs *x509.CertPool
+
+	// credentialsDir is the directory url/token/ca.crt were read from, if any
+	credentialsDir string
+
+	// sources records where each setting came from, keyed by flag name
+	sources map[string]string
 }
 
 // LoadConfig loads configuration from command line flags and environment variables
 func LoadConfig() *Config {
 	// Command line flags
-	baseURL := flag.String("url", "", "Nextcloud base URL (e.g., https://cloud.example.com)")
-	token := flag.String("token", "", "NC-Token for authentication")
+	baseURL := flag.String("url", "", "Nextcloud base URL, or comma-separated list of URLs (e.g., https://cloud.example.com)")
+	statusURL := flag.String("status-url", "", "Base URL for status.php when it differs from -url, or comma-separated list matching -url")
+	token := flag.String("token", "", "NC-Token for authentication, or comma-separated list matching -url")
 	listenAddr := flag.String("listen", "", "Address to listen on (default :9205)")
+	healthListenAddr := flag.String("web-health-listen", "", "Separate address for health endpoints (default: serve them on -listen)")
+	disableLandingPage := flag.Bool("disable-landing-page", false, "Return 404 for / instead of the HTML landing page")
 	fetchInterval := flag.Duration("fetch-interval", 0, "Minimum interval between API fetches to avoid rate limiting (default 30s)")
 	timeout := flag.Duration("timeout", 0, "HTTP client timeout (default 10s)")
+	infoMetricType := flag.String("info-metric-type", "", "Value type for info metrics: gauge or untyped (default gauge)")
+	logLevel := flag.String("log-level", "", "Log level: info or debug (default info)")
+	freeSpaceWarnBytes := flag.Int64("freespace-warn-bytes", 0, "Free space threshold in bytes below which nextcloud_system_freespace_below_threshold is 1 (0 = disabled)")
+	scrapeCapabilities := flag.Bool("scrape-capabilities", false, "Also fetch the OCS capabilities endpoint and expose boolean capabilities")
+	aggregate := flag.Bool("aggregate", false, "With multiple instances, also emit fleet-wide totals without instance labels")
+	emitZerosOnFailure := flag.Bool("emit-zeros-on-failure", false, "Emit serverinfo metrics as NaN when a fetch fails and no cached data exists")
+	activeUserPeriodsFlag := flag.String("active-user-periods", "", "Comma-separated active user periods to emit, e.g. 5min,24hours,1month (default all)")
+	appInfoLimit := flag.Int("app-info-limit", 0, "Maximum number of installed apps to expose as nextcloud_app_info (0 = disabled)")
+	cpuLoadEMAAlpha := flag.Float64("cpuload-ema-alpha", 0, "Smoothing factor (0-1] for nextcloud_system_cpuload_smoothed (0 = disabled)")
+	traceRequests := flag.Bool("trace-requests", false, "Log DNS, connect, TLS and time-to-first-byte durations of upstream requests (requires -log-level debug)")
+	cacheFilePath := flag.String("cache-file", "", "File to persist the last successful responses to, served after a restart until a fresh fetch succeeds")
+	cacheMaxAge := flag.Duration("cache-max-age", 0, "Maximum age of persisted responses served from -cache-file, checked when the file is loaded at startup (default 1h)")
+	serverinfoMethod := flag.String("serverinfo-method", "", "HTTP method for serverinfo requests: GET or POST, for gateways that reject GET (default GET)")
+	waitForFirstScrape := flag.Bool("wait-for-first-scrape", false, "Fetch serverinfo at startup and report /-/ready as 503 until the first fetch succeeds")
+	activeUsersFallback := flag.Bool("active-users-fallback", false, "Fetch active users from the dedicated serverinfo activeUsers endpoint when the main payload omits them")
+	once := flag.Bool("once", false, "Scrape once, push the metrics to -push-gateway and exit")
+	pushGateway := flag.String("push-gateway", "", "Pushgateway URL to push metrics to with -once")
+	pushJob := flag.String("push-job", "", "Job label for metrics pushed with -once (default nextcloud_exporter)")
+	maxConnections := flag.Int("max-connections", 0, "Maximum concurrent connections per listener; further connections wait (default 100)")
+	tlsServerName := flag.String("tls-server-name", "", "Hostname to verify the upstream certificate against (and send as SNI) when connecting by IP or internal name")
+	resolver := flag.String("resolver", "", "DNS server (host[:port]) to resolve upstream hostnames with, instead of the system resolver")
+	strictDecode := flag.Bool("strict-decode", false, "Detect unknown status.php fields and count them in nextcloud_unknown_fields_total (for testing new Nextcloud versions)")
+	extraEndpointsFlag := flag.String("extra-endpoints", "", "Comma-separated extra OCS endpoints to scrape (available: "+strings.Join(extraEndpointNames(), ", ")+")")
+	linkNoPasswordWarn := flag.Int("link-no-password-warn", 0, "Number of link shares without password above which nextcloud_shares_link_no_password_exceeds_threshold is 1 (0 = disabled)")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Disable upstream TLS certificate verification (for self-signed certificates; insecure)")
+	caCertFile := flag.String("ca-cert", "", "PEM file with CA certificates to verify the upstream certificate against")
+	mockMode := flag.Bool("mock-mode", false, "Allow URLs without a scheme and no token, for testing against mock servers (requires NEXTCLOUD_EXPORTER_ALLOW_MOCK_MODE=1)")
+	ocsAPIRequestHeader := flag.Bool("ocs-apirequest-header", true, "Send the OCS-APIRequest: true header with OCS requests (avoids CSRF redirects to the login page)")
+	skipSuspiciousZeros := flag.Bool("skip-suspicious-zeros", false, "Skip storage and free space metrics when num_users, num_files and freespace are all zero (likely a bad payload)")
+	instancesFile := flag.String("instances-file", "", "JSON file listing instances as [{\"url\": ..., \"token\": ..., \"status_url\": ...}] instead of -url/-token")
+	extraEndpointsUser := flag.String("extra-endpoints-user", "", "Nextcloud user for extra endpoints that require a user login (e.g. activity)")
+	extraEndpointsPassword := flag.String("extra-endpoints-password", "", "Password or app password of -extra-endpoints-user")
+	capabilitiesFlag := flag.String("capabilities", "", "Comma-separated boolean capabilities to expose with -scrape-capabilities, e.g. files_sharing.public.enabled (default a built-in list of sharing and files capabilities)")
+	credentialsDir := flag.String("credentials-dir", "", "Directory containing url, token and optional ca.crt files (e.g., a mounted secret)")
 	flag.Parse()
 
+	setFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
 	config := &Config{
-		BaseURL:       *baseURL,
-		Token:         *token,
-		ListenAddr:    *listenAddr,
-		FetchInterval: *fetchInterval,
-		Timeout:       *timeout,
+		ListenAddr:             *listenAddr,
+		HealthListenAddr:       *healthListenAddr,
+		DisableLandingPage:     *disableLandingPage,
+		FetchInterval:          *fetchInterval,
+		Timeout:                *timeout,
+		InfoMetricType:         *infoMetricType,
+		LogLevel:               *logLevel,
+		FreeSpaceWarnBytes:     *freeSpaceWarnBytes,
+		ScrapeCapabilities:     *scrapeCapabilities,
+		Aggregate:              *aggregate,
+		EmitZerosOnFailure:     *emitZerosOnFailure,
+		AppInfoLimit:           *appInfoLimit,
+		CPULoadEMAAlpha:        *cpuLoadEMAAlpha,
+		TraceRequests:          *traceRequests,
+		CacheFile:              *cacheFilePath,
+		CacheMaxAge:            *cacheMaxAge,
+		ServerinfoMethod:       *serverinfoMethod,
+		WaitForFirstScrape:     *waitForFirstScrape,
+		ActiveUsersFallback:    *activeUsersFallback,
+		Once:                   *once,
+		PushGateway:            *pushGateway,
+		PushJob:                *pushJob,
+		MaxConnections:         *maxConnections,
+		TLSServerName:          *tlsServerName,
+		Resolver:               *resolver,
+		StrictDecode:           *strictDecode,
+		LinkNoPasswordWarn:     *linkNoPasswordWarn,
+		InsecureSkipVerify:     *insecureSkipVerify,
+		CACertFile:             *caCertFile,
+		MockMode:               *mockMode,
+		OCSAPIRequestHeader:    *ocsAPIRequestHeader,
+		SkipSuspiciousZeros:    *skipSuspiciousZeros,
+		InstancesFile:          *instancesFile,
+		ExtraEndpointsUser:     *extraEndpointsUser,
+		ExtraEndpointsPassword: *extraEndpointsPassword,
 	}
 
 	// Use environment variables as fallback
-	if config.BaseURL == "" {
-		config.BaseURL = getEnv("NEXTCLOUD_URL", "")
+	if *baseURL == "" {
+		*baseURL = getEnv("NEXTCLOUD_URL", "")
+	}
+	if *token == "" {
+		*token = getEnv("NC_TOKEN", "")
+	}
+	if *statusURL == "" {
+		*statusURL = getEnv("STATUS_URL", "")
 	}
-	if config.Token == "" {
-		config.Token = getEnv("NC_TOKEN", "")
+	if *credentialsDir == "" {
+		*credentialsDir = getEnv("CREDENTIALS_DIR", "")
+	}
+	fromCredentials := map[string]bool{}
+	if *credentialsDir != "" {
+		creds, err := readCredentialsDir(*credentialsDir)
+		if err != nil {
+			log.Fatalf("Error reading credentials directory: %v", err)
+		}
+		if *baseURL == "" {
+			*baseURL = creds.url
+			fromCredentials["url"] = true
+		}
+		if *token == "" {
+			*token = creds.token
+			fromCredentials["token"] = true
+		}
+		config.RootCAs = creds.rootCAs
+		config.credentialsDir = *credentialsDir
 	}
 	if config.ListenAddr == "" {
 		config.ListenAddr = getEnv("LISTEN_ADDR", DefaultListenAddr)
 	}
+	if config.HealthListenAddr == "" {
+		config.HealthListenAddr = getEnv("HEALTH_LISTEN_ADDR", "")
+	}
+	if !config.DisableLandingPage {
+		config.DisableLandingPage = getEnvBool("DISABLE_LANDING_PAGE", false)
+	}
 	if config.FetchInterval == 0 {
 		config.FetchInterval = getEnvDuration("FETCH_INTERVAL", DefaultFetchInterval)
 	}
 	if config.Timeout == 0 {
 		config.Timeout = getEnvDuration("TIMEOUT", DefaultTimeout)
 	}
+	if config.InfoMetricType == "" {
+		config.InfoMetricType = getEnv("INFO_METRIC_TYPE", DefaultInfoMetricType)
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = getEnv("LOG_LEVEL", DefaultLogLevel)
+	}
+	if config.FreeSpaceWarnBytes == 0 {
+		config.FreeSpaceWarnBytes = getEnvInt64("FREESPACE_WARN_BYTES", 0)
+	}
+	if !config.ScrapeCapabilities {
+		config.ScrapeCapabilities = getEnvBool("SCRAPE_CAPABILITIES", false)
+	}
+	if !config.Aggregate {
+		config.Aggregate = getEnvBool("AGGREGATE", false)
+	}
+	if !config.EmitZerosOnFailure {
+		config.EmitZerosOnFailure = getEnvBool("EMIT_ZEROS_ON_FAILURE", false)
+	}
+	periods := *activeUserPeriodsFlag
+	if periods == "" {
+		periods = getEnv("ACTIVE_USER_PERIODS", strings.Join(activeUserPeriods, ","))
+	}
+	config.ActiveUserPeriods = splitList(periods)
+	extras := *extraEndpointsFlag
+	if extras == "" {
+		extras = getEnv("EXTRA_ENDPOINTS", "")
+	}
+	config.ExtraEndpoints = map[string]bool{}
+	for _, name := range splitList(extras) {
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(extraEndpointNames(), name) {
+			log.Fatalf("Invalid extra endpoint %q. Must be one of %s", name, strings.Join(extraEndpointNames(), ", "))
+		}
+		config.ExtraEndpoints[name] = true
+	}
+	if config.AppInfoLimit == 0 {
+		config.AppInfoLimit = int(getEnvInt64("APP_INFO_LIMIT", 0))
+	}
+	if config.CPULoadEMAAlpha == 0 {
+		config.CPULoadEMAAlpha = getEnvFloat("CPULOAD_EMA_ALPHA", 0)
+	}
+	if !config.TraceRequests {
+		config.TraceRequests = getEnvBool("TRACE_REQUESTS", false)
+	}
+	if config.CacheFile == "" {
+		config.CacheFile = getEnv("CACHE_FILE", "")
+	}
+	if config.CacheMaxAge == 0 {
+		config.CacheMaxAge = getEnvDuration("CACHE_MAX_AGE", DefaultCacheMaxAge)
+	}
+	if config.ServerinfoMethod == "" {
+		config.ServerinfoMethod = getEnv("SERVERINFO_METHOD", DefaultServerinfoMethod)
+	}
+	if !config.WaitForFirstScrape {
+		config.WaitForFirstScrape = getEnvBool("WAIT_FOR_FIRST_SCRAPE", false)
+	}
+	if !config.ActiveUsersFallback {
+		config.ActiveUsersFallback = getEnvBool("ACTIVE_USERS_FALLBACK", false)
+	}
+	if !config.Once {
+		config.Once = getEnvBool("ONCE", false)
+	}
+	if config.PushGateway == "" {
+		config.PushGateway = getEnv("PUSH_GATEWAY", "")
+	}
+	if config.PushJob == "" {
+		config.PushJob = getEnv("PUSH_JOB", DefaultPushJob)
+	}
+	if config.MaxConnections == 0 {
+		config.MaxConnections = int(getEnvInt64("MAX_CONNECTIONS", DefaultMaxConnections))
+	}
+	if config.TLSServerName == "" {
+		config.TLSServerName = getEnv("TLS_SERVER_NAME", "")
+	}
+	if config.Resolver == "" {
+		config.Resolver = getEnv("RESOLVER", "")
+	}
+	if !config.StrictDecode {
+		config.StrictDecode = getEnvBool("STRICT_DECODE", false)
+	}
+	if config.LinkNoPasswordWarn == 0 {
+		config.LinkNoPasswordWarn = int(getEnvInt64("LINK_NO_PASSWORD_WARN", 0))
+	}
+	if !config.InsecureSkipVerify {
+		config.InsecureSkipVerify = getEnvBool("INSECURE_SKIP_VERIFY", false)
+	}
+	if config.CACertFile == "" {
+		config.CACertFile = getEnv("CA_CERT_FILE", "")
+	}
+	if config.CACertFile != "" {
+		pool, err := loadCertPool(config.CACertFile)
+		if err != nil {
+			log.Fatalf("Error loading CA certificate: %v", err)
+		}
+		config.RootCAs = pool
+	}
+	if !config.MockMode {
+		config.MockMode = getEnvBool("MOCK_MODE", false)
+	}
+	if config.MockMode {
+		if os.Getenv(allowMockModeEnv) != "1" {
+			log.Fatalf("-mock-mode is for testing only and requires %s=1", allowMockModeEnv)
+		}
+		log.Print("WARNING: mock mode enabled, URL validation and the token requirement are relaxed")
+	}
+	if !setFlags["ocs-apirequest-header"] {
+		config.OCSAPIRequestHeader = getEnvBool("OCS_APIREQUEST_HEADER", true)
+	}
+	if !config.SkipSuspiciousZeros {
+		config.SkipSuspiciousZeros = getEnvBool("SKIP_SUSPICIOUS_ZEROS", false)
+	}
+	if config.InstancesFile == "" {
+		config.InstancesFile = getEnv("INSTANCES_FILE", "")
+	}
+	if config.ExtraEndpointsUser == "" {
+		config.ExtraEndpointsUser = getEnv("EXTRA_ENDPOINTS_USER", "")
+	}
+	if config.ExtraEndpointsPassword == "" {
+		config.ExtraEndpointsPassword = getEnv("EXTRA_ENDPOINTS_PASSWORD", "")
+	}
+	for _, endpoint := range extraEndpoints {
+		if endpoint.userAuth && config.ExtraEndpoints[endpoint.name] && (config.ExtraEndpointsUser == "" || config.ExtraEndpointsPassword == "") {
+			log.Fatalf("Extra endpoint %q requires -extra-endpoints-user and -extra-endpoints-password", endpoint.name)
+		}
+	}
+	capabilities := *capabilitiesFlag
+	if capabilities == "" {
+		capabilities = getEnv("CAPABILITIES", strings.Join(defaultCapabilities, ","))
+	}
+	for _, name := range splitList(capabilities) {
+		if name != "" {
+			config.Capabilities = append(config.Capabilities, name)
+		}
+	}
 
 	// Validate required parameters
-	if config.BaseURL == "" {
-		log.Fatal("Nextcloud URL is required. Set via -url flag or NEXTCLOUD_URL environment variable")
+	var instances []Instance
+	if config.InstancesFile != "" {
+		if *baseURL != "" || *token != "" || *statusURL != "" {
+			log.Fatal("-instances-file cannot be combined with -url, -token, -status-url or -credentials-dir")
+		}
+		var err error
+		instances, err = readInstancesFile(config.InstancesFile)
+		if err != nil {
+			log.Fatalf("Error reading instances file: %v", err)
+		}
+	} else {
+		if *baseURL == "" {
+			log.Fatal("Nextcloud URL is required. Set via -url flag or NEXTCLOUD_URL environment variable")
+		}
+		if *token == "" && !config.MockMode {
+			log.Fatal("NC-Token is required. Set via -token flag or NC_TOKEN environment variable")
+		}
+
+		urls := splitList(*baseURL)
+		tokens := splitList(*token)
+		if config.MockMode && *token == "" {
+			tokens = make([]string, len(urls))
+		}
+		if len(urls) != len(tokens) {
+			log.Fatalf("Number of URLs (%d) does not match number of tokens (%d)", len(urls), len(tokens))
+		}
+		statusURLs := urls
+		if *statusURL != "" {
+			statusURLs = splitList(*statusURL)
+			if len(statusURLs) != len(urls) {
+				log.Fatalf("Number of status URLs (%d) does not match number of URLs (%d)", len(statusURLs), len(urls))
+			}
+		}
+		for i := range urls {
+			instances = append(instances, Instance{BaseURL: urls[i], Token: tokens[i], StatusURL: statusURLs[i]})
+		}
+	}
+	seen := map[string]bool{}
+	for _, instance := range instances {
+		if instance.BaseURL == "" || (instance.Token == "" && !config.MockMode) {
+			log.Fatal("Every instance needs a non-empty URL and token")
+		}
+		if config.MockMode {
+			instance.BaseURL = withDefaultScheme(instance.BaseURL)
+			instance.StatusURL = withDefaultScheme(instance.StatusURL)
+		}
+		if err := validateBaseURL(instance.BaseURL); err != nil {
+			log.Fatalf("Invalid URL: %v", err)
+		}
+		if err := validateBaseURL(instance.StatusURL); err != nil {
+			log.Fatalf("Invalid status URL: %v", err)
+		}
+		// The URL is the instance label, so a duplicate would collide at registration
+		if seen[instance.BaseURL] {
+			log.Fatalf("Duplicate instance URL %q", instance.BaseURL)
+		}
+		seen[instance.BaseURL] = true
+		config.Instances = append(config.Instances, instance)
+	}
+	config.ServerinfoMethod = strings.ToUpper(config.ServerinfoMethod)
+	if config.ServerinfoMethod != "GET" && config.ServerinfoMethod != "POST" {
+		log.Fatalf("Invalid serverinfo method %q. Must be GET or POST", config.ServerinfoMethod)
+	}
+	if config.InfoMetricType != "gauge" && config.InfoMetricType != "untyped" {
+		log.Fatalf("Invalid info metric type %q. Must be gauge or untyped", config.InfoMetricType)
+	}
+	if config.HealthListenAddr != "" && config.HealthListenAddr == config.ListenAddr {
+		log.Fatal("Health listen address must differ from the metrics listen address")
+	}
+	if config.FreeSpaceWarnBytes < 0 {
+		log.Fatal("Free space warning threshold must not be negative")
+	}
+	if resolver := config.Resolver; resolver != "" {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			config.Resolver = net.JoinHostPort(resolver, "53")
+		}
+		if host, _, err := net.SplitHostPort(config.Resolver); err != nil || host == "" {
+			log.Fatalf("Invalid resolver address %q. Must be host[:port]", resolver)
+		}
+	}
+	if config.MaxConnections < 1 {
+		log.Fatal("Max connections must be positive")
+	}
+	if config.LinkNoPasswordWarn < 0 {
+		log.Fatal("Link shares without password warning threshold must not be negative")
+	}
+	if config.AppInfoLimit < 0 {
+		log.Fatal("App info limit must not be negative")
+	}
+	if config.CPULoadEMAAlpha < 0 || config.CPULoadEMAAlpha > 1 {
+		log.Fatalf("Invalid CPU load EMA alpha %g. Must be between 0 and 1", config.CPULoadEMAAlpha)
+	}
+	for _, period := range config.ActiveUserPeriods {
+		if !slices.Contains(activeUserPeriods, period) {
+			log.Fatalf("Invalid active user period %q. Must be one of %s", period, strings.Join(activeUserPeriods, ", "))
+		}
+	}
+	if config.LogLevel != "info" && config.LogLevel != "debug" {
+		log.Fatalf("Invalid log level %q. Must be info or debug", config.LogLevel)
+	}
+	if config.TLSServerName != "" {
+		for _, instance := range config.Instances {
+			for _, u := range []string{instance.BaseURL, instance.StatusURL} {
+				if err := validateHTTPSURL(u); err != nil {
+					log.Fatalf("-tls-server-name requires https URLs: %v", err)
+				}
+			}
+		}
 	}
-	if config.Token == "" {
-		log.Fatal("NC-Token is required. Set via -token flag or NC_TOKEN environment variable")
+	if config.Once != (config.PushGateway != "") {
+		log.Fatal("-once and -push-gateway must be used together")
+	}
+	if config.InsecureSkipVerify {
+		log.Printf("WARNING: TLS certificate verification is disabled (-insecure-skip-verify); upstream connections are not protected against interception")
+	}
+	if config.TraceRequests && config.LogLevel != "debug" {
+		log.Printf("Warning: -trace-requests has no effect unless the log level is debug")
+	}
+
+	// Record where each setting came from: flags win over environment
+	// variables, which win over the credentials directory and defaults
+	config.sources = map[string]string{}
+	for _, setting := range config.settings() {
+		switch {
+		case setFlags[setting.flag]:
+			config.sources[setting.flag] = "flag"
+		case os.Getenv(setting.env) != "":
+			config.sources[setting.flag] = "env"
+		case fromCredentials[setting.flag]:
+			config.sources[setting.flag] = "credentials-dir"
+		default:
+			config.sources[setting.flag] = "default"
+		}
 	}
 
 	return config
 }
 
+// enabledFeatures reports which optional behaviors are active, keyed by
+// nextcloud_exporter_features_info label name
+func (c *Config) enabledFeatures() map[string]bool {
+	return map[string]bool{
+		"multi_instance":        len(c.Instances) > 1,
+		"aggregate":             c.Aggregate && len(c.Instances) > 1,
+		"capabilities":          c.ScrapeCapabilities,
+		"custom_ca":             c.RootCAs != nil,
+		"cache_file":            c.CacheFile != "",
+		"wait_for_first_scrape": c.WaitForFirstScrape,
+		"cpuload_ema":           c.CPULoadEMAAlpha > 0,
+		"insecure_skip_verify":  c.InsecureSkipVerify,
+		"tls_server_name":       c.TLSServerName != "",
+		"resolver":              c.Resolver != "",
+		"strict_decode":         c.StrictDecode,
+		"extra_endpoints":       len(c.ExtraEndpoints) > 0,
+	}
+}
+
+// configSetting is one resolved setting in the effective-config log line
+type configSetting struct {
+	flag  string
+	env   string
+	value string
+}
+
+// settings lists the resolved configuration by flag name, with secrets redacted
+func (c *Config) settings() []configSetting {
+	urls := make([]string, len(c.Instances))
+	statusURLs := make([]string, len(c.Instances))
+	for i, instance := range c.Instances {
+		urls[i] = instance.BaseURL
+		statusURLs[i] = instance.StatusURL
+	}
+	return []configSetting{
+		{"url", "NEXTCLOUD_URL", strings.Join(urls, ",")},
+		{"status-url", "STATUS_URL", strings.Join(statusURLs, ",")},
+		{"token", "NC_TOKEN", "<redacted>"},
+		{"credentials-dir", "CREDENTIALS_DIR", c.credentialsDir},
+		{"listen", "LISTEN_ADDR", c.ListenAddr},
+		{"web-health-listen", "HEALTH_LISTEN_ADDR", c.HealthListenAddr},
+		{"disable-landing-page", "DISABLE_LANDING_PAGE", strconv.FormatBool(c.DisableLandingPage)},
+		{"fetch-interval", "FETCH_INTERVAL", c.FetchInterval.String()},
+		{"timeout", "TIMEOUT", c.Timeout.String()},
+		{"info-metric-type", "INFO_METRIC_TYPE", c.InfoMetricType},
+		{"log-level", "LOG_LEVEL", c.LogLevel},
+		{"freespace-warn-bytes", "FREESPACE_WARN_BYTES", strconv.FormatInt(c.FreeSpaceWarnBytes, 10)},
+		{"scrape-capabilities", "SCRAPE_CAPABILITIES", strconv.FormatBool(c.ScrapeCapabilities)},
+		{"aggregate", "AGGREGATE", strconv.FormatBool(c.Aggregate)},
+		{"emit-zeros-on-failure", "EMIT_ZEROS_ON_FAILURE", strconv.FormatBool(c.EmitZerosOnFailure)},
+		{"active-user-periods", "ACTIVE_USER_PERIODS", strings.Join(c.ActiveUserPeriods, ",")},
+		{"app-info-limit", "APP_INFO_LIMIT", strconv.Itoa(c.AppInfoLimit)},
+		{"cpuload-ema-alpha", "CPULOAD_EMA_ALPHA", strconv.FormatFloat(c.CPULoadEMAAlpha, 'g', -1, 64)},
+		{"trace-requests", "TRACE_REQUESTS", strconv.FormatBool(c.TraceRequests)},
+		{"cache-file", "CACHE_FILE", c.CacheFile},
+		{"cache-max-age", "CACHE_MAX_AGE", c.CacheMaxAge.String()},
+		{"serverinfo-method", "SERVERINFO_METHOD", c.ServerinfoMethod},
+		{"wait-for-first-scrape", "WAIT_FOR_FIRST_SCRAPE", strconv.FormatBool(c.WaitForFirstScrape)},
+		{"active-users-fallback", "ACTIVE_USERS_FALLBACK", strconv.FormatBool(c.ActiveUsersFallback)},
+		{"once", "ONCE", strconv.FormatBool(c.Once)},
+		{"push-gateway", "PUSH_GATEWAY", c.PushGateway},
+		{"push-job", "PUSH_JOB", c.PushJob},
+		{"max-connections", "MAX_CONNECTIONS", strconv.Itoa(c.MaxConnections)},
+		{"tls-server-name", "TLS_SERVER_NAME", c.TLSServerName},
+		{"resolver", "RESOLVER", c.Resolver},
+		{"strict-decode", "STRICT_DECODE", strconv.FormatBool(c.StrictDecode)},
+		{"extra-endpoints", "EXTRA_ENDPOINTS", strings.Join(slices.Sorted(maps.Keys(c.ExtraEndpoints)), ",")},
+		{"link-no-password-warn", "LINK_NO_PASSWORD_WARN", strconv.Itoa(c.LinkNoPasswordWarn)},
+		{"insecure-skip-verify", "INSECURE_SKIP_VERIFY", strconv.FormatBool(c.InsecureSkipVerify)},
+		{"ca-cert", "CA_CERT_FILE", c.CACertFile},
+		{"mock-mode", "MOCK_MODE", strconv.FormatBool(c.MockMode)},
+		{"ocs-apirequest-header", "OCS_APIREQUEST_HEADER", strconv.FormatBool(c.OCSAPIRequestHeader)},
+		{"skip-suspicious-zeros", "SKIP_SUSPICIOUS_ZEROS", strconv.FormatBool(c.SkipSuspiciousZeros)},
+		{"instances-file", "INSTANCES_FILE", c.InstancesFile},
+		{"extra-endpoints-user", "EXTRA_ENDPOINTS_USER", c.ExtraEndpointsUser},
+		{"extra-endpoints-password", "EXTRA_ENDPOINTS_PASSWORD", "<redacted>"},
+		{"capabilities", "CAPABILITIES", strings.Join(c.Capabilities, ",")},
+	}
+}
+
+// LogEffective logs every resolved setting and its source (flag, env, credentials-dir or default) on one line
+func (c *Config) LogEffective() {
+	parts := make([]string, 0, len(c.sources))
+	for _, setting := range c.settings() {
+		parts = append(parts, fmt.Sprintf("%s=%q(%s)", setting.flag, setting.value, c.sources[setting.flag]))
+	}
+	log.Printf("Effective config: %s", strings.Join(parts, " "))
+}
+
+// hash returns an FNV-1a hash of the effective setting values. Secrets are
+// redacted in settings() and so do not contribute. 32 bits keep the value
+// exactly representable as a metric sample.
+func (c *Config) hash() uint32 {
+	h := fnv.New32a()
+	for _, setting := range c.settings() {
+		fmt.Fprintf(h, "%s=%s\n", setting.flag, setting.value)
+	}
+	return h.Sum32()
+}
+
+// instanceFileEntry is one instance in an -instances-file
+type instanceFileEntry struct {
+	URL       string `json:"url"`
+	Token     string `json:"token"`
+	StatusURL string `json:"status_url"`
+}
+
+// readInstancesFile reads the instances from a JSON array of url/token/status_url
+// objects. status_url defaults to url.
+func readInstancesFile(path string) ([]Instance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []instanceFileEntry
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s lists no instances", path)
+	}
+	instances := make([]Instance, len(entries))
+	for i, entry := range entries {
+		instances[i] = Instance{BaseURL: entry.URL, Token: entry.Token, StatusURL: entry.StatusURL}
+		if entry.StatusURL == "" {
+			instances[i].StatusURL = entry.URL
+		}
+	}
+	return instances, nil
+}
+
+// credentials holds the values read from a credentials directory
+type credentials struct {
+	url     string
+	token   string
+	rootCAs *x509.CertPool
+}
+
+// readCredentialsDir reads the url and token files, and the ca.crt file when
+// present, from a directory laid out like a mounted Kubernetes secret
+func readCredentialsDir(dir string) (*credentials, error) {
+	url, err := readTrimmedFile(filepath.Join(dir, "url"))
+	if err != nil {
+		return nil, err
+	}
+	token, err := readTrimmedFile(filepath.Join(dir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	creds := &credentials{url: url, token: token}
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if _, err := os.Stat(caFile); err == nil {
+		creds.rootCAs, err = loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return creds, nil
+}
+
+// readTrimmedFile reads a file and strips surrounding whitespace
+func readTrimmedFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return value, nil
+}
+
+// loadCertPool builds a certificate pool from a PEM file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificate %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// validateBaseURL checks that raw is an absolute http(s) URL
+func validateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q must be an absolute http or https URL", raw)
+	}
+	return nil
+}
+
+// validateHTTPSURL checks that raw is an https URL; the scheme is case-insensitive
+func validateHTTPSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(u.Scheme, "https") {
+		return fmt.Errorf("%q is not an https URL", raw)
+	}
+	return nil
+}
+
+// withDefaultScheme prefixes raw with http:// when it has no scheme, so mock mode accepts bare host:port addresses
+func withDefaultScheme(raw string) string {
+	if strings.Contains(raw, "://") {
+		return raw
+	}
+	return "http://" + raw
+}
+
+// splitList splits a comma-separated value into trimmed entries
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -81,6 +828,36 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+		log.Printf("Warning: invalid integer value for %s: %s, using default", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("Warning: invalid number value for %s: %s, using default", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("Warning: invalid boolean value for %s: %s, using default", key, value)
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		// Try parsing as duration string (e.g., "30s", "1m")
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestValidateHTTPSURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://cloud.example.com", true},
+		{"HTTPS://cloud.example.com", true},
+		{"Https://10.0.0.5:8443/nextcloud", true},
+		{"http://cloud.example.com", false},
+		{"HTTP://cloud.example.com", false},
+		{"cloud.example.com", false},
+	}
+	for _, tt := range tests {
+		err := validateHTTPSURL(tt.url)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got error %v, want valid %t", tt.url, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Scrape failure reasons reported by nextcloud_scrape_error
+const (
+	reasonNetwork     = "network"
+	reasonRateLimited = "rate_limited"
+	reasonHTTPStatus  = "http_status"
+	reasonProxy       = "proxy"
+	reasonParse       = "parse"
+	reasonEmptyData   = "empty_data"
+	reasonUnknown     = "unknown"
+)
+
+// Authentication outcomes reported by nextcloud_auth_results_total
+const (
+	authSuccess      = "success"
+	authUnauthorized = "unauthorized"
+	authForbidden    = "forbidden"
+)
+
+// scrapeError is a fetch error classified by the reason it failed
+type scrapeError struct {
+	reason string
+	err    error
+
+	// HTTP status code of an unexpected response, 0 otherwise
+	statusCode int
+}
+
+func (e *scrapeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *scrapeError) Unwrap() error {
+	return e.err
+}
+
+// newScrapeError wraps err with a failure reason
+func newScrapeError(reason string, err error) error {
+	return &scrapeError{reason: reason, err: err}
+}
+
+// newHTTPStatusError reports an unexpected HTTP status code
+func newHTTPStatusError(code int) error {
+	return &scrapeError{reason: reasonHTTPStatus, err: fmt.Errorf("unexpected status code: %d", code), statusCode: code}
+}
+
+// authResult classifies the outcome of an authenticated fetch. ok is false
+// when the outcome says nothing about authentication (e.g. a network error).
+func authResult(err error) (result string, ok bool) {
+	if err == nil {
+		return authSuccess, true
+	}
+	var se *scrapeError
+	if !errors.As(err, &se) {
+		return "", false
+	}
+	switch se.statusCode {
+	case http.StatusUnauthorized:
+		return authUnauthorized, true
+	case http.StatusForbidden:
+		return authForbidden, true
+	}
+	return "", false
+}
+
+// failureReason returns the reason of a classified error, or reasonUnknown
+func failureReason(err error) string {
+	var se *scrapeError
+	if errors.As(err, &se) {
+		return se.reason
+	}
+	return reasonUnknown
+}
+
+// isUnknownFieldError reports whether err is the error a json.Decoder with
+// DisallowUnknownFields returns for an unknown field. encoding/json has no
+// typed error for it, so this matches the message; keep it the only place
+// that does.
+func isUnknownFieldError(err error) bool {
+	return strings.HasPrefix(err.Error(), "json: unknown field ")
+}
+
+// isTimeout reports whether err was caused by a context deadline or a client/network timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
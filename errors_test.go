@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{newScrapeError(reasonParse, errors.New("parsing JSON: unexpected EOF")), reasonParse},
+		{newScrapeError(reasonNetwork, errors.New("executing request: connection refused")), reasonNetwork},
+		{fmt.Errorf("fetching: %w", newScrapeError(reasonParse, errors.New("bad"))), reasonParse},
+		{newHTTPStatusError(502), reasonHTTPStatus},
+		{errors.New("unclassified"), reasonUnknown},
+	}
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFetchErrorClasses(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		upstream := newFakeNextcloud(t, "serverinfo_truncated.json", nil)
+		_, err := newTestCollector(upstream, nil).fetchData()
+		if got := failureReason(err); got != reasonParse {
+			t.Errorf("got reason %q (%v), want %q", got, err, reasonParse)
+		}
+	})
+	t.Run("network", func(t *testing.T) {
+		upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+		c := newTestCollector(upstream, nil)
+		upstream.Close()
+		_, err := c.fetchData()
+		if got := failureReason(err); got != reasonNetwork {
+			t.Errorf("got reason %q (%v), want %q", got, err, reasonNetwork)
+		}
+	})
+}
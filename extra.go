@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// extraEndpoint is an OCS app endpoint scraped in addition to serverinfo.
+// Each one is enabled by name with -extra-endpoints.
+type extraEndpoint struct {
+	name string
+	path string
+
+	// userAuth marks endpoints that reject the NC-Token and need the
+	// -extra-endpoints-user login sent as basic auth
+	userAuth bool
+
+	// collect decodes the OCS data object of a response and emits its metrics
+	collect func(c *NextcloudCollector, ch chan<- prometheus.Metric, data json.RawMessage) error
+}
+
+// extraEndpoints is the registry of available extra endpoints
+var extraEndpoints = []extraEndpoint{
+	{
+		name:     "activity",
+		path:     "/ocs/v2.php/apps/activity/api/v2/activity?format=json&limit=1",
+		userAuth: true,
+		collect:  collectActivity,
+	},
+}
+
+// extraEndpointNames returns the names of all registered extra endpoints
+func extraEndpointNames() []string {
+	names := make([]string, len(extraEndpoints))
+	for i, endpoint := range extraEndpoints {
+		names[i] = endpoint.name
+	}
+	return names
+}
+
+// extraResponse is the generic OCS envelope of an extra endpoint
+type extraResponse struct {
+	OCS struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"ocs"`
+}
+
+// cachedExtra is the last successful response of an extra endpoint
+type cachedExtra struct {
+	data      json.RawMessage
+	fetchedAt time.Time
+}
+
+// collectExtraMetrics fetches the enabled extra endpoints and emits their metrics.
+// A failing endpoint is logged and skipped without affecting the others.
+func (c *NextcloudCollector) collectExtraMetrics(ch chan<- prometheus.Metric) {
+	for _, endpoint := range extraEndpoints {
+		if !c.config.ExtraEndpoints[endpoint.name] {
+			continue
+		}
+		data, err := c.fetchExtraCached(endpoint)
+		if err != nil {
+			log.Printf("Error fetching %s: %v", endpoint.name, err)
+			continue
+		}
+		if data == nil {
+			continue
+		}
+		c.collectExtra(ch, endpoint, data)
+	}
+}
+
+func (c *NextcloudCollector) collectExtra(ch chan<- prometheus.Metric, endpoint extraEndpoint, data json.RawMessage) {
+	defer c.recoverCollectPanic(endpoint.name)
+
+	if err := endpoint.collect(c, ch, data); err != nil {
+		log.Printf("Error decoding %s: %v", endpoint.name, err)
+	}
+}
+
+// fetchExtraCached returns the cached OCS data of an endpoint if within fetch interval, otherwise fetches fresh data
+func (c *NextcloudCollector) fetchExtraCached(endpoint extraEndpoint) (json.RawMessage, error) {
+	c.cacheMu.RLock()
+	cached, ok := c.cachedExtras[endpoint.name]
+	c.cacheMu.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < c.config.FetchInterval {
+		return cached.data, nil
+	}
+
+	data, err := c.fetchExtra(endpoint)
+	if err != nil {
+		if ok {
+			log.Printf("Using cached %s data due to fetch error: %v", endpoint.name, err)
+			return cached.data, nil
+		}
+		return nil, err
+	}
+
+	c.cacheMu.Lock()
+	if c.cachedExtras == nil {
+		c.cachedExtras = map[string]cachedExtra{}
+	}
+	c.cachedExtras[endpoint.name] = cachedExtra{data: data, fetchedAt: time.Now()}
+	c.cacheMu.Unlock()
+
+	return data, nil
+}
+
+// fetchExtra returns the OCS data object of an extra endpoint. An endpoint that
+// is not installed (404) yields nil without an error.
+func (c *NextcloudCollector) fetchExtra(endpoint extraEndpoint) (json.RawMessage, error) {
+	url := c.instance.BaseURL + endpoint.path
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	c.setOCSHeaders(req)
+	if endpoint.userAuth {
+		req.SetBasicAuth(c.config.ExtraEndpointsUser, c.config.ExtraEndpointsPassword)
+	}
+
+	req = c.withTrace(req, endpoint.name)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("executing request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		c.debugf("%s: endpoint not installed, skipping", endpoint.name)
+		return nil, nil
+	case http.StatusTooManyRequests:
+		return nil, newScrapeError(reasonRateLimited, fmt.Errorf("rate limited (429): too many requests"))
+	}
+
+	if !isSuccessStatus(resp.StatusCode) {
+		return nil, newHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newScrapeError(reasonNetwork, fmt.Errorf("reading response body: %w", err))
+	}
+	c.bytesRead[endpoint.name].Add(uint64(len(body)))
+
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	var data extraResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, newScrapeError(reasonParse, fmt.Errorf("parsing JSON: %w", err))
+	}
+	return data.OCS.Data, nil
+}
+
+// collectActivity emits the id of the newest activity visible to the
+// configured user. Ids grow with every recorded event, but they are not a
+// count, so the value is a gauge.
+func collectActivity(c *NextcloudCollector, ch chan<- prometheus.Metric, data json.RawMessage) error {
+	var activities []struct {
+		ActivityID int64 `json:"activity_id"`
+	}
+	if err := json.Unmarshal(data, &activities); err != nil {
+		return err
+	}
+	if len(activities) == 0 {
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics.ActivityLatestID, prometheus.GaugeValue, float64(activities[0].ActivityID))
+	return nil
+}
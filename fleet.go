@@ -0,0 +1,64 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// FleetCollector sums selected serverinfo values across all instances, for
+// dashboards that want fleet-wide totals without per-instance labels
+type FleetCollector struct {
+	collectors []*NextcloudCollector
+
+	usersTotal  *prometheus.Desc
+	filesTotal  *prometheus.Desc
+	sharesTotal *prometheus.Desc
+}
+
+// NewFleetCollector creates a collector aggregating the given instance collectors
+func NewFleetCollector(collectors []*NextcloudCollector) *FleetCollector {
+	return &FleetCollector{
+		collectors: collectors,
+		usersTotal: prometheus.NewDesc(
+			"nextcloud_users_fleet_total",
+			"Total number of users across all instances",
+			nil, nil,
+		),
+		filesTotal: prometheus.NewDesc(
+			"nextcloud_files_fleet_total",
+			"Total number of files across all instances",
+			nil, nil,
+		),
+		sharesTotal: prometheus.NewDesc(
+			"nextcloud_shares_fleet_total",
+			"Total number of shares across all instances",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (f *FleetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.usersTotal
+	ch <- f.filesTotal
+	ch <- f.sharesTotal
+}
+
+// Collect implements prometheus.Collector. It only reads the data the instance
+// collectors last fetched, so it never adds upstream requests or moves their
+// delta baselines. Instances without data are left out of the sums; their own
+// nextcloud_scrape_success reports the failure.
+func (f *FleetCollector) Collect(ch chan<- prometheus.Metric) {
+	var users, files, shares int
+	for _, c := range f.collectors {
+		data := c.cachedServerinfo()
+		if data == nil {
+			continue
+		}
+		nc := data.OCS.Data.Nextcloud
+		users += nc.Storage.NumUsers
+		files += nc.Storage.NumFiles
+		shares += nc.Shares.NumShares
+	}
+
+	ch <- prometheus.MustNewConstMetric(f.usersTotal, prometheus.GaugeValue, float64(users))
+	ch <- prometheus.MustNewConstMetric(f.filesTotal, prometheus.GaugeValue, float64(files))
+	ch <- prometheus.MustNewConstMetric(f.sharesTotal, prometheus.GaugeValue, float64(shares))
+}
@@ -1,37 +1,174 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"golang.org/x/net/netutil"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take once a shutdown signal arrives
+const shutdownTimeout = 5 * time.Second
+
 func main() {
 	// Load configuration
 	config := LoadConfig()
+	config.LogEffective()
+	if err := NewMetricDescriptors().checkUnique(); err != nil {
+		log.Fatalf("Invalid metric descriptors: %v", err)
+	}
+
+	// Create and register one collector per instance. With several instances,
+	// each collector's metrics carry an instance label to keep them apart.
+	var cache *cacheFile
+	if config.CacheFile != "" {
+		cache = loadCacheFile(config.CacheFile, config.CacheMaxAge)
+	}
+	var collectors []*NextcloudCollector
+	// The instance and fleet collectors get their own registry so that push mode
+	// sends only them, without the exporter's own go_*, process_* and HTTP metrics
+	instanceRegistry := prometheus.NewRegistry()
+	// /public-metrics has its own registry so that only the availability metrics are exposed there
+	publicRegistry := prometheus.NewRegistry()
+	for _, instance := range config.Instances {
+		collector := NewNextcloudCollector(config, instance)
+		if cache != nil {
+			collector.restoreCache(cache)
+		}
+		collectors = append(collectors, collector)
+		if len(config.Instances) == 1 {
+			instanceRegistry.MustRegister(collector)
+			publicRegistry.MustRegister(NewPublicCollector(collector))
+			continue
+		}
+		instanceLabels := prometheus.Labels{"instance": instance.BaseURL}
+		prometheus.WrapRegistererWith(instanceLabels, instanceRegistry).MustRegister(collector)
+		prometheus.WrapRegistererWith(instanceLabels, publicRegistry).MustRegister(NewPublicCollector(collector))
+	}
+	if config.Aggregate && len(collectors) > 1 {
+		instanceRegistry.MustRegister(NewFleetCollector(collectors))
+	}
 
-	// Create and register collector
-	collector := NewNextcloudCollector(config)
-	prometheus.MustRegister(collector)
+	// In push mode, scrape once and exit instead of serving
+	if config.Once {
+		if err := push.New(config.PushGateway, config.PushJob).Gatherer(instanceRegistry).Push(); err != nil {
+			log.Fatalf("Error pushing metrics to %s: %v", config.PushGateway, err)
+		}
+		log.Printf("Pushed metrics to %s", config.PushGateway)
+		return
+	}
 
-	// Setup HTTP server
-	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`<html>
+	// Setup HTTP server, instrumenting the metrics handler itself
+	httpRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nextcloud_exporter_http_requests_total",
+		Help: "Number of HTTP requests served by the exporter",
+	}, []string{"code", "handler"})
+	httpDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nextcloud_exporter_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests served by the exporter in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"code", "handler"})
+	prometheus.MustRegister(httpRequests, httpDuration)
+	metricsLabels := prometheus.Labels{"handler": "/metrics"}
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.Gatherers{instanceRegistry, prometheus.DefaultGatherer}, promhttp.HandlerOpts{}))
+	http.Handle("/metrics", promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(metricsLabels),
+		promhttp.InstrumentHandlerDuration(httpDuration.MustCurryWith(metricsLabels), metricsHandler)))
+	http.Handle("/public-metrics", promhttp.HandlerFor(publicRegistry, promhttp.HandlerOpts{}))
+	http.HandleFunc("/status", statusHandler(collectors))
+	if config.DisableLandingPage {
+		http.HandleFunc("/", http.NotFound)
+	} else {
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<html>
 <head><title>Nextcloud Exporter</title></head>
 <body>
 <h1>Nextcloud Exporter</h1>
 <p><a href="/metrics">Metrics</a></p>
 </body>
 </html>`))
+		})
+	}
+
+	// Health endpoints share the main listener unless a separate one is configured
+	healthMux := http.DefaultServeMux
+	if config.HealthListenAddr != "" {
+		healthMux = http.NewServeMux()
+	}
+	healthMux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	healthMux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		if config.WaitForFirstScrape {
+			for _, collector := range collectors {
+				if !collector.Ready() {
+					http.Error(w, "Waiting for first successful fetch", http.StatusServiceUnavailable)
+					return
+				}
+			}
+		}
+		w.Write([]byte("OK"))
 	})
 
+	servers := []*http.Server{{Addr: config.ListenAddr}}
+	if config.HealthListenAddr != "" {
+		servers = append(servers, &http.Server{Addr: config.HealthListenAddr, Handler: healthMux})
+	}
+
 	log.Printf("Starting Nextcloud exporter on %s", config.ListenAddr)
-	log.Printf("Fetching metrics from: %s", config.BaseURL)
+	if config.HealthListenAddr != "" {
+		log.Printf("Serving health endpoints on %s", config.HealthListenAddr)
+	}
+	for _, instance := range config.Instances {
+		log.Printf("Fetching metrics from: %s", instance.BaseURL)
+	}
 	log.Printf("Fetch interval: %s (to avoid rate limiting)", config.FetchInterval)
-	if err := http.ListenAndServe(config.ListenAddr, nil); err != nil {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if config.WaitForFirstScrape {
+		for _, collector := range collectors {
+			go collector.WarmUp(ctx)
+		}
+	}
+
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			log.Fatalf("Error starting HTTP server: %v", err)
+		}
+		listener = netutil.LimitListener(listener, config.MaxConnections)
+		go func(server *http.Server, listener net.Listener) {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}(server, listener)
+	}
+
+	select {
+	case err := <-errCh:
 		log.Fatalf("Error starting HTTP server: %v", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down HTTP server on %s: %v", server.Addr, err)
+		}
 	}
 }
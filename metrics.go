@@ -1,6 +1,42 @@
 package main
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cpuLoadIntervals are the interval label values of nextcloud_system_cpuload, in API order
+var cpuLoadIntervals = []string{"1m", "5m", "15m"}
+
+// activeUserPeriods are the period label values of nextcloud_active_users
+var activeUserPeriods = []string{"5min", "1hour", "24hours", "7days", "1month", "3months", "6months", "1year"}
+
+// defaultCapabilities are the boolean capabilities exposed as nextcloud_capability
+// unless -capabilities lists others
+var defaultCapabilities = []string{
+	"files.bigfilechunking",
+	"files.undelete",
+	"files.versioning",
+	"files_sharing.api_enabled",
+	"files_sharing.federation.incoming",
+	"files_sharing.federation.outgoing",
+	"files_sharing.public.enabled",
+	"files_sharing.public.expire_date.enabled",
+	"files_sharing.public.expire_date.enforced",
+	"files_sharing.public.password.enforced",
+	"files_sharing.public.upload",
+	"files_sharing.resharing",
+}
+
+// upstreamEndpoints are the endpoint label values of nextcloud_upstream_bytes_read_total
+var upstreamEndpoints = append([]string{"status", "serverinfo", "activeUsers", "capabilities"}, extraEndpointNames()...)
+
+// authResultLabels are the result label values of nextcloud_auth_results_total
+var authResultLabels = []string{authSuccess, authUnauthorized, authForbidden}
+
+// exporterFeatures are the label names of nextcloud_exporter_features_info
+var exporterFeatures = []string{"multi_instance", "aggregate", "capabilities", "custom_ca", "cache_file", "wait_for_first_scrape", "cpuload_ema", "insecure_skip_verify", "tls_server_name", "resolver", "strict_decode", "extra_endpoints"}
 
 // MetricDescriptors holds all prometheus metric descriptors
 type MetricDescriptors struct {
@@ -11,273 +47,702 @@ type MetricDescriptors struct {
 	StatusNeedsDbUpgrade  *prometheus.Desc
 	StatusExtendedSupport *prometheus.Desc
 
+	// Maintenance consistency between status.php and serverinfo
+	MaintenanceStateConsistent *prometheus.Desc
+
 	// System metrics
-	SystemInfo *prometheus.Desc
-	FreeSpace  *prometheus.Desc
-	CPULoad    *prometheus.Desc
-	CPUCount   *prometheus.Desc
-	MemTotal   *prometheus.Desc
-	MemFree    *prometheus.Desc
-	SwapTotal  *prometheus.Desc
-	SwapFree   *prometheus.Desc
+	SystemInfo              *prometheus.Desc
+	FreeSpace               *prometheus.Desc
+	FreeSpaceBelowThreshold *prometheus.Desc
+	CPULoad                 *prometheus.Desc
+	CPULoadSmoothed         *prometheus.Desc
+	CPUCount                *prometheus.Desc
+	MemTotal                *prometheus.Desc
+	MemFree                 *prometheus.Desc
+	SwapTotal               *prometheus.Desc
+	SwapFree                *prometheus.Desc
+	SwapConfigured          *prometheus.Desc
+	CacheBackendInfo        *prometheus.Desc
+	DebugEnabled            *prometheus.Desc
+	LocaleInfo              *prometheus.Desc
 
 	// Apps metrics
-	AppsInstalled        *prometheus.Desc
-	AppsUpdatesAvailable *prometheus.Desc
+	AppsInstalled                *prometheus.Desc
+	AppsUpdatesAvailable         *prometheus.Desc
+	AppsSecurityUpdatesAvailable *prometheus.Desc
+	AppInfo                      *prometheus.Desc
 
 	// Update metrics
-	UpdateAvailable *prometheus.Desc
+	UpdateAvailable  *prometheus.Desc
+	UpdatesAvailable *prometheus.Desc
 
 	// Storage metrics
-	UsersTotal         *prometheus.Desc
-	FilesTotal         *prometheus.Desc
-	StoragesTotal      *prometheus.Desc
-	StoragesLocalTotal *prometheus.Desc
-	StoragesHomeTotal  *prometheus.Desc
-	StoragesOtherTotal *prometheus.Desc
+	UsersTotal            *prometheus.Desc
+	UsersAdded            *prometheus.Desc
+	FilesTotal            *prometheus.Desc
+	FilesPerUser          *prometheus.Desc
+	FilesByStorage        *prometheus.Desc
+	StoragesTotal         *prometheus.Desc
+	StoragesLocalTotal    *prometheus.Desc
+	StoragesHomeTotal     *prometheus.Desc
+	StoragesOtherTotal    *prometheus.Desc
+	StoragesExternalTotal *prometheus.Desc
 
 	// Shares metrics
-	SharesTotal                  *prometheus.Desc
-	SharesUserTotal              *prometheus.Desc
-	SharesGroupsTotal            *prometheus.Desc
-	SharesLinkTotal              *prometheus.Desc
-	SharesMailTotal              *prometheus.Desc
-	SharesRoomTotal              *prometheus.Desc
-	SharesLinkNoPasswordTotal    *prometheus.Desc
-	SharesFederatedSentTotal     *prometheus.Desc
-	SharesFederatedReceivedTotal *prometheus.Desc
+	SharesTotal                          *prometheus.Desc
+	SharesCreatedDelta                   *prometheus.Desc
+	SharesUserTotal                      *prometheus.Desc
+	SharesGroupsTotal                    *prometheus.Desc
+	SharesLinkTotal                      *prometheus.Desc
+	SharesMailTotal                      *prometheus.Desc
+	SharesRoomTotal                      *prometheus.Desc
+	SharesRoomRatio                      *prometheus.Desc
+	SharesLinkNoPasswordTotal            *prometheus.Desc
+	SharesLinkNoPasswordRatio            *prometheus.Desc
+	SharesLinkNoPasswordExceedsThreshold *prometheus.Desc
+	SharesLinkToUserRatio                *prometheus.Desc
+	SharesFederatedSentTotal             *prometheus.Desc
+	SharesFederatedReceivedTotal         *prometheus.Desc
 
 	// Server metrics
-	PHPMemoryLimit       *prometheus.Desc
-	PHPUploadMaxFilesize *prometheus.Desc
-	PHPOpcacheMemoryUsed *prometheus.Desc
-	PHPOpcacheMemoryFree *prometheus.Desc
-	PHPOpcacheHitRate    *prometheus.Desc
-	DatabaseSize         *prometheus.Desc
+	PHPMemoryLimit                   *prometheus.Desc
+	PHPInfo                          *prometheus.Desc
+	PHPVersionEOL                    *prometheus.Desc
+	UsersPerPHPMemoryMB              *prometheus.Desc
+	PHPUploadMaxFilesize             *prometheus.Desc
+	PHPOpcacheMemoryUsed             *prometheus.Desc
+	PHPOpcacheMemoryUsedMax          *prometheus.Desc
+	PHPOpcacheJITBufferUsed          *prometheus.Desc
+	PHPOpcacheJITBufferFree          *prometheus.Desc
+	PHPOpcacheMemoryFree             *prometheus.Desc
+	PHPOpcacheMemoryWasted           *prometheus.Desc
+	PHPOpcacheHitRate                *prometheus.Desc
+	PHPOpcacheHits                   *prometheus.Desc
+	PHPOpcacheMisses                 *prometheus.Desc
+	PHPOpcacheBlacklistMissRatio     *prometheus.Desc
+	PHPOpcacheRestarts               *prometheus.Desc
+	PHPOpcacheLastRestart            *prometheus.Desc
+	DatabaseSize                     *prometheus.Desc
+	DatabaseInfo                     *prometheus.Desc
+	DatabaseSizeAvailable            *prometheus.Desc
+	DatabaseMissingIndices           *prometheus.Desc
+	DatabasePendingBigintConversions *prometheus.Desc
 
 	// Active users metrics
-	ActiveUsers *prometheus.Desc
+	ActiveUsers            *prometheus.Desc
+	ActiveUsersDailyGrowth *prometheus.Desc
+
+	// Capabilities metrics
+	Capability       *prometheus.Desc
+	ActivityLatestID *prometheus.Desc
+
+	// Upstream TLS metrics
+	UpstreamTLSCertExpiry   *prometheus.Desc
+	UpstreamTLSCertNotAfter *prometheus.Desc
+	UpstreamTLSEnabled      *prometheus.Desc
+	UpstreamBytesRead       *prometheus.Desc
+	AuthResults             *prometheus.Desc
+
+	// Exporter metrics
+	ExporterAuthConfigured *prometheus.Desc
+	ExporterFeaturesInfo   *prometheus.Desc
+	ExporterConfigHash     *prometheus.Desc
+	ExporterTargetInfo     *prometheus.Desc
+	ExporterScrapeTime     *prometheus.Desc
 
 	// Scrape metrics
-	ScrapeSuccess *prometheus.Desc
+	ScrapeSuccess         *prometheus.Desc
+	ScrapeError           *prometheus.Desc
+	ScrapeTimedOut        *prometheus.Desc
+	ServerinfoEmptyData   *prometheus.Desc
+	SuspiciousZeroPayload *prometheus.Desc
+	CachePartial          *prometheus.Desc
+	CacheValidFor         *prometheus.Desc
+	ScrapesTotal          *prometheus.Desc
+	ScrapeInFlight        *prometheus.Desc
+	CollectPanicTotal     *prometheus.Desc
+	InvalidMetricValues   *prometheus.Desc
+	UnknownFieldsTotal    *prometheus.Desc
+	FieldCasingVariants   *prometheus.Desc
+
+	// Metric names of all descriptors above, in creation order, and their help text
+	names []string
+	help  map[string]string
 }
 
-// NewMetricDescriptors creates all metric descriptors
+// NewMetricDescriptors creates all metric descriptors.
+// Help text states the unit: byte values end in "in bytes", ratios in "(0-1)",
+// percentages in "in percent (0-100)" and durations in "in seconds".
 func NewMetricDescriptors() *MetricDescriptors {
-	return &MetricDescriptors{
+	var names []string
+	helpByName := map[string]string{}
+	desc := func(name, help string, labels []string) *prometheus.Desc {
+		names = append(names, name)
+		helpByName[name] = help
+		return prometheus.NewDesc(name, help, labels, nil)
+	}
+
+	m := &MetricDescriptors{
 		// Status metrics (from /status.php)
-		StatusInfo: prometheus.NewDesc(
+		StatusInfo: desc(
 			"nextcloud_status_info",
 			"Nextcloud status information",
-			[]string{"version", "versionstring", "productname", "edition"}, nil,
+			[]string{"version", "versionstring", "productname", "edition"},
 		),
-		StatusInstalled: prometheus.NewDesc(
+		StatusInstalled: desc(
 			"nextcloud_status_installed",
 			"Nextcloud installation status (1 = installed, 0 = not installed)",
-			nil, nil,
+			nil,
 		),
-		StatusMaintenance: prometheus.NewDesc(
+		StatusMaintenance: desc(
 			"nextcloud_status_maintenance",
 			"Nextcloud maintenance mode (1 = enabled, 0 = disabled)",
-			nil, nil,
+			nil,
 		),
-		StatusNeedsDbUpgrade: prometheus.NewDesc(
+		StatusNeedsDbUpgrade: desc(
 			"nextcloud_status_needs_db_upgrade",
 			"Nextcloud needs database upgrade (1 = yes, 0 = no)",
-			nil, nil,
+			nil,
 		),
-		StatusExtendedSupport: prometheus.NewDesc(
+		StatusExtendedSupport: desc(
 			"nextcloud_status_extended_support",
 			"Nextcloud extended support status (1 = enabled, 0 = disabled)",
-			nil, nil,
+			nil,
+		),
+
+		// Maintenance consistency between status.php and serverinfo
+		MaintenanceStateConsistent: desc(
+			"nextcloud_maintenance_state_consistent",
+			"Whether status.php and serverinfo agree on maintenance mode (1 = agree, 0 = disagree)",
+			nil,
 		),
 
 		// System metrics
-		SystemInfo: prometheus.NewDesc(
+		SystemInfo: desc(
 			"nextcloud_system_info",
 			"Nextcloud system information",
-			[]string{"version"}, nil,
+			[]string{"version", "channel"},
 		),
-		FreeSpace: prometheus.NewDesc(
+		FreeSpace: desc(
 			"nextcloud_system_freespace_bytes",
 			"Free disk space in bytes",
-			nil, nil,
+			nil,
+		),
+		FreeSpaceBelowThreshold: desc(
+			"nextcloud_system_freespace_below_threshold",
+			"Whether free disk space is below the configured threshold (1 = below, 0 = above)",
+			nil,
 		),
-		CPULoad: prometheus.NewDesc(
+		CPULoad: desc(
 			"nextcloud_system_cpuload",
 			"CPU load average",
-			[]string{"interval"}, nil,
+			[]string{"interval"},
 		),
-		CPUCount: prometheus.NewDesc(
+		CPULoadSmoothed: desc(
+			"nextcloud_system_cpuload_smoothed",
+			"CPU load average smoothed with an exponential moving average across fetches",
+			[]string{"interval"},
+		),
+		CPUCount: desc(
 			"nextcloud_system_cpu_count",
 			"Number of CPUs",
-			nil, nil,
+			nil,
 		),
-		MemTotal: prometheus.NewDesc(
+		MemTotal: desc(
 			"nextcloud_system_mem_total_bytes",
 			"Total memory in bytes",
-			nil, nil,
+			nil,
 		),
-		MemFree: prometheus.NewDesc(
+		MemFree: desc(
 			"nextcloud_system_mem_free_bytes",
 			"Free memory in bytes",
-			nil, nil,
+			nil,
 		),
-		SwapTotal: prometheus.NewDesc(
+		SwapTotal: desc(
 			"nextcloud_system_swap_total_bytes",
 			"Total swap in bytes",
-			nil, nil,
+			nil,
 		),
-		SwapFree: prometheus.NewDesc(
+		SwapFree: desc(
 			"nextcloud_system_swap_free_bytes",
 			"Free swap in bytes",
-			nil, nil,
+			nil,
+		),
+		SwapConfigured: desc(
+			"nextcloud_system_swap_configured",
+			"Whether the host has swap configured (0/1)",
+			nil,
+		),
+		CacheBackendInfo: desc(
+			"nextcloud_cache_backend_info",
+			"Configured memcache backend by cache type",
+			[]string{"type", "backend"},
+		),
+		DebugEnabled: desc(
+			"nextcloud_debug_enabled",
+			"Whether Nextcloud debug mode is enabled (0/1)",
+			nil,
+		),
+		LocaleInfo: desc(
+			"nextcloud_locale_info",
+			"Configured default timezone and phone region",
+			[]string{"timezone", "phone_region"},
 		),
 
 		// Apps metrics
-		AppsInstalled: prometheus.NewDesc(
+		AppsInstalled: desc(
 			"nextcloud_apps_installed_total",
 			"Number of installed apps",
-			nil, nil,
+			nil,
 		),
-		AppsUpdatesAvailable: prometheus.NewDesc(
+		AppsUpdatesAvailable: desc(
 			"nextcloud_apps_updates_available_total",
 			"Number of app updates available",
-			nil, nil,
+			nil,
+		),
+		AppsSecurityUpdatesAvailable: desc(
+			"nextcloud_apps_security_updates_available_total",
+			"Number of app updates available that are security updates",
+			nil,
+		),
+		AppInfo: desc(
+			"nextcloud_app_info",
+			"Installed Nextcloud app information",
+			[]string{"app", "version"},
 		),
 
 		// Update metrics
-		UpdateAvailable: prometheus.NewDesc(
+		UpdateAvailable: desc(
 			"nextcloud_update_available",
 			"Nextcloud update available (1 = yes, 0 = no)",
-			[]string{"available_version"}, nil,
+			[]string{"available_version"},
+		),
+		UpdatesAvailable: desc(
+			"nextcloud_updates_available",
+			"Number of available updates by type (core is 0/1)",
+			[]string{"type"},
 		),
 
 		// Storage metrics
-		UsersTotal: prometheus.NewDesc(
+		UsersTotal: desc(
 			"nextcloud_users_total",
 			"Total number of users",
-			nil, nil,
+			nil,
 		),
-		FilesTotal: prometheus.NewDesc(
+		UsersAdded: desc(
+			"nextcloud_users_added",
+			"Number of users added since the previous fetch (0 on reset)",
+			nil,
+		),
+		FilesTotal: desc(
 			"nextcloud_files_total",
 			"Total number of files",
-			nil, nil,
+			nil,
+		),
+		FilesPerUser: desc(
+			"nextcloud_files_per_user",
+			"Average number of files per user",
+			nil,
 		),
-		StoragesTotal: prometheus.NewDesc(
+		FilesByStorage: desc(
+			"nextcloud_files_by_storage",
+			"Number of files by storage type",
+			[]string{"type"},
+		),
+		StoragesTotal: desc(
 			"nextcloud_storages_total",
 			"Total number of storages",
-			nil, nil,
+			nil,
 		),
-		StoragesLocalTotal: prometheus.NewDesc(
+		StoragesLocalTotal: desc(
 			"nextcloud_storages_local_total",
 			"Number of local storages",
-			nil, nil,
+			nil,
 		),
-		StoragesHomeTotal: prometheus.NewDesc(
+		StoragesHomeTotal: desc(
 			"nextcloud_storages_home_total",
 			"Number of home storages",
-			nil, nil,
+			nil,
 		),
-		StoragesOtherTotal: prometheus.NewDesc(
+		StoragesOtherTotal: desc(
 			"nextcloud_storages_other_total",
 			"Number of other storages",
-			nil, nil,
+			nil,
+		),
+		StoragesExternalTotal: desc(
+			"nextcloud_storages_external_total",
+			"Number of external storage mounts (S3, SMB, etc.)",
+			nil,
 		),
 
 		// Shares metrics
-		SharesTotal: prometheus.NewDesc(
+		SharesTotal: desc(
 			"nextcloud_shares_total",
 			"Total number of shares",
-			nil, nil,
+			nil,
+		),
+		SharesCreatedDelta: desc(
+			"nextcloud_shares_created_delta",
+			"Number of shares created since the previous fetch (0 on reset)",
+			nil,
 		),
-		SharesUserTotal: prometheus.NewDesc(
+		SharesUserTotal: desc(
 			"nextcloud_shares_user_total",
 			"Number of user shares",
-			nil, nil,
+			nil,
 		),
-		SharesGroupsTotal: prometheus.NewDesc(
+		SharesGroupsTotal: desc(
 			"nextcloud_shares_groups_total",
 			"Number of group shares",
-			nil, nil,
+			nil,
 		),
-		SharesLinkTotal: prometheus.NewDesc(
+		SharesLinkTotal: desc(
 			"nextcloud_shares_link_total",
 			"Number of link shares",
-			nil, nil,
+			nil,
 		),
-		SharesMailTotal: prometheus.NewDesc(
+		SharesMailTotal: desc(
 			"nextcloud_shares_mail_total",
 			"Number of mail shares",
-			nil, nil,
+			nil,
 		),
-		SharesRoomTotal: prometheus.NewDesc(
+		SharesRoomTotal: desc(
 			"nextcloud_shares_room_total",
 			"Number of room shares",
-			nil, nil,
+			nil,
 		),
-		SharesLinkNoPasswordTotal: prometheus.NewDesc(
+		SharesRoomRatio: desc(
+			"nextcloud_shares_room_ratio",
+			"Fraction of shares that are Talk room shares (0-1)",
+			nil,
+		),
+		SharesLinkNoPasswordTotal: desc(
 			"nextcloud_shares_link_no_password_total",
 			"Number of link shares without password",
-			nil, nil,
+			nil,
+		),
+		SharesLinkNoPasswordRatio: desc(
+			"nextcloud_shares_link_no_password_ratio",
+			"Fraction of link shares without password (0-1)",
+			nil,
+		),
+		SharesLinkNoPasswordExceedsThreshold: desc(
+			"nextcloud_shares_link_no_password_exceeds_threshold",
+			"Whether link shares without password exceed the configured threshold (0/1)",
+			nil,
+		),
+		SharesLinkToUserRatio: desc(
+			"nextcloud_shares_link_to_user_ratio",
+			"Number of link shares per user share (0 when there are no user shares)",
+			nil,
 		),
-		SharesFederatedSentTotal: prometheus.NewDesc(
+		SharesFederatedSentTotal: desc(
 			"nextcloud_shares_federated_sent_total",
 			"Number of federated shares sent",
-			nil, nil,
+			nil,
 		),
-		SharesFederatedReceivedTotal: prometheus.NewDesc(
+		SharesFederatedReceivedTotal: desc(
 			"nextcloud_shares_federated_received_total",
 			"Number of federated shares received",
-			nil, nil,
+			nil,
 		),
 
 		// Server metrics
-		PHPMemoryLimit: prometheus.NewDesc(
+		PHPMemoryLimit: desc(
 			"nextcloud_php_memory_limit_bytes",
-			"PHP memory limit in bytes",
-			nil, nil,
+			"PHP memory limit in bytes (-1 = unlimited)",
+			nil,
 		),
-		PHPUploadMaxFilesize: prometheus.NewDesc(
+		PHPInfo: desc(
+			"nextcloud_php_info",
+			"Running PHP version",
+			[]string{"version"},
+		),
+		PHPVersionEOL: desc(
+			"nextcloud_php_version_eol",
+			"Whether the running PHP version is past its end of security support (0/1)",
+			[]string{"eol_date"},
+		),
+		UsersPerPHPMemoryMB: desc(
+			"nextcloud_users_per_php_memory_mb",
+			"Users per MiB of PHP memory limit, a rough capacity estimate",
+			nil,
+		),
+		PHPUploadMaxFilesize: desc(
 			"nextcloud_php_upload_max_filesize_bytes",
 			"PHP upload max filesize in bytes",
-			nil, nil,
+			nil,
 		),
-		PHPOpcacheMemoryUsed: prometheus.NewDesc(
+		PHPOpcacheMemoryUsed: desc(
 			"nextcloud_php_opcache_memory_used_bytes",
 			"PHP OPcache used memory in bytes",
-			nil, nil,
+			nil,
+		),
+		PHPOpcacheMemoryUsedMax: desc(
+			"nextcloud_php_opcache_memory_used_max_bytes",
+			"Highest OPcache used memory seen since the exporter started in bytes",
+			nil,
 		),
-		PHPOpcacheMemoryFree: prometheus.NewDesc(
+		PHPOpcacheJITBufferUsed: desc(
+			"nextcloud_php_opcache_jit_buffer_used_bytes",
+			"OPcache JIT buffer used in bytes",
+			nil,
+		),
+		PHPOpcacheJITBufferFree: desc(
+			"nextcloud_php_opcache_jit_buffer_free_bytes",
+			"OPcache JIT buffer free in bytes",
+			nil,
+		),
+		PHPOpcacheMemoryFree: desc(
 			"nextcloud_php_opcache_memory_free_bytes",
 			"PHP OPcache free memory in bytes",
-			nil, nil,
+			nil,
 		),
-		PHPOpcacheHitRate: prometheus.NewDesc(
-			"nextcloud_php_opcache_hit_rate",
-			"PHP OPcache hit rate percentage",
-			nil, nil,
+		PHPOpcacheMemoryWasted: desc(
+			"nextcloud_php_opcache_memory_wasted_bytes",
+			"PHP OPcache wasted memory in bytes",
+			nil,
 		),
-		DatabaseSize: prometheus.NewDesc(
+		PHPOpcacheHitRate: desc(
+			"nextcloud_php_opcache_hit_rate",
+			"PHP OPcache hit rate in percent (0-100)",
+			nil,
+		),
+		PHPOpcacheHits: desc(
+			"nextcloud_php_opcache_hits_total",
+			"Total number of PHP OPcache hits since PHP started",
+			nil,
+		),
+		PHPOpcacheMisses: desc(
+			"nextcloud_php_opcache_misses_total",
+			"Total number of PHP OPcache misses since PHP started",
+			nil,
+		),
+		PHPOpcacheBlacklistMissRatio: desc(
+			"nextcloud_php_opcache_blacklist_miss_ratio",
+			"PHP OPcache blacklist miss ratio (0-1)",
+			nil,
+		),
+		PHPOpcacheRestarts: desc(
+			"nextcloud_php_opcache_restarts_total",
+			"Total number of PHP OPcache restarts by reason",
+			[]string{"reason"},
+		),
+		PHPOpcacheLastRestart: desc(
+			"nextcloud_php_opcache_last_restart_seconds",
+			"Time of the last PHP OPcache restart as a Unix timestamp in seconds",
+			nil,
+		),
+		DatabaseSize: desc(
 			"nextcloud_database_size_bytes",
 			"Database size in bytes",
-			nil, nil,
+			nil,
+		),
+		DatabaseInfo: desc(
+			"nextcloud_database_info",
+			"Database type and version",
+			[]string{"type", "version"},
+		),
+		DatabaseSizeAvailable: desc(
+			"nextcloud_database_size_available",
+			"Whether the database size was reported and parseable (0/1)",
+			nil,
+		),
+		DatabaseMissingIndices: desc(
+			"nextcloud_database_missing_indices",
+			"Number of missing database indices (occ db:add-missing-indices)",
+			nil,
+		),
+		DatabasePendingBigintConversions: desc(
+			"nextcloud_database_pending_bigint_conversions",
+			"Number of columns pending conversion to bigint (occ db:convert-filecache-bigint)",
+			nil,
 		),
 
 		// Active users metrics
-		ActiveUsers: prometheus.NewDesc(
+		ActiveUsers: desc(
 			"nextcloud_active_users",
 			"Number of active users",
-			[]string{"period"}, nil,
+			[]string{"period"},
+		),
+		ActiveUsersDailyGrowth: desc(
+			"nextcloud_active_users_daily_growth",
+			"Increase in users active in the last 24 hours since the previous fetch (0 on decrease)",
+			nil,
+		),
+
+		// Capabilities metrics
+		Capability: desc(
+			"nextcloud_capability",
+			"Nextcloud boolean capability from the OCS capabilities endpoint (1 = enabled, 0 = disabled)",
+			[]string{"name"},
+		),
+		ActivityLatestID: desc(
+			"nextcloud_activity_latest_id",
+			"Id of the newest activity app event visible to the extra endpoints user",
+			nil,
+		),
+
+		// Upstream TLS metrics
+		UpstreamTLSCertExpiry: desc(
+			"nextcloud_upstream_tls_cert_expiry_seconds",
+			"Time until the upstream TLS certificate expires in seconds",
+			nil,
+		),
+		UpstreamTLSCertNotAfter: desc(
+			"nextcloud_upstream_tls_cert_not_after_seconds",
+			"Expiry of the upstream TLS certificate as a Unix timestamp in seconds",
+			nil,
+		),
+		UpstreamTLSEnabled: desc(
+			"nextcloud_upstream_tls_enabled",
+			"Whether the upstream base URL uses https (0/1)",
+			nil,
+		),
+		UpstreamBytesRead: desc(
+			"nextcloud_upstream_bytes_read_total",
+			"Response body bytes read from upstream by endpoint",
+			[]string{"endpoint"},
+		),
+		AuthResults: desc(
+			"nextcloud_auth_results_total",
+			"Serverinfo fetches by authentication outcome",
+			[]string{"result"},
+		),
+
+		// Exporter metrics
+		ExporterAuthConfigured: desc(
+			"nextcloud_exporter_auth_configured",
+			"Authentication method the exporter is configured to use for the instance",
+			[]string{"method"},
+		),
+		ExporterFeaturesInfo: desc(
+			"nextcloud_exporter_features_info",
+			"Optional exporter behaviors that are enabled, as true/false labels",
+			exporterFeatures,
+		),
+		ExporterConfigHash: desc(
+			"nextcloud_exporter_config_hash",
+			"Hash of the effective configuration, excluding secrets",
+			nil,
+		),
+		ExporterTargetInfo: desc(
+			"nextcloud_exporter_target_info",
+			"Nextcloud host the exporter scrapes, without path or credentials",
+			[]string{"url"},
+		),
+		ExporterScrapeTime: desc(
+			"nextcloud_exporter_scrape_time_seconds",
+			"Exporter clock at collect time as a Unix timestamp in seconds",
+			nil,
 		),
 
 		// Scrape metrics
-		ScrapeSuccess: prometheus.NewDesc(
+		ScrapeSuccess: desc(
 			"nextcloud_scrape_success",
 			"Whether the scrape was successful (1 = success, 0 = failure)",
-			nil, nil,
+			nil,
+		),
+		ScrapeError: desc(
+			"nextcloud_scrape_error",
+			"Reason the serverinfo fetch failed (network, rate_limited, http_status, proxy, parse, empty_data, unknown), only present on failure",
+			[]string{"reason"},
+		),
+		ScrapeTimedOut: desc(
+			"nextcloud_scrape_timed_out",
+			"Whether the last serverinfo fetch failed because of a timeout (1 = timed out, 0 = otherwise)",
+			nil,
+		),
+		ServerinfoEmptyData: desc(
+			"nextcloud_serverinfo_empty_data",
+			"Whether the last serverinfo fetch returned an empty data array instead of an object",
+			nil,
+		),
+		SuspiciousZeroPayload: desc(
+			"nextcloud_suspicious_zero_payload",
+			"Whether users, files and free space were all reported as zero and skipped as a likely bad payload (0/1)",
+			nil,
+		),
+		CachePartial: desc(
+			"nextcloud_cache_partial",
+			"Whether only one of status.php and serverinfo data is fresh, so metrics mix fresh and stale data (0/1)",
+			nil,
+		),
+		CacheValidFor: desc(
+			"nextcloud_cache_valid_for_seconds",
+			"Time until cached serverinfo data expires and the next upstream fetch happens, in seconds",
+			nil,
+		),
+		ScrapesTotal: desc(
+			"nextcloud_scrapes_total",
+			"Total number of scrapes since the exporter started",
+			nil,
+		),
+		ScrapeInFlight: desc(
+			"nextcloud_scrape_in_flight",
+			"Number of scrapes currently being collected, including this one",
+			nil,
+		),
+		CollectPanicTotal: desc(
+			"nextcloud_collect_panic_total",
+			"Total number of panics recovered while building metrics",
+			nil,
+		),
+		InvalidMetricValues: desc(
+			"nextcloud_invalid_metric_values_total",
+			"Total number of non-finite upstream values skipped instead of being emitted",
+			[]string{"metric"},
+		),
+		UnknownFieldsTotal: desc(
+			"nextcloud_unknown_fields_total",
+			"Number of status.php responses containing fields the exporter does not know",
+			nil,
+		),
+		FieldCasingVariants: desc(
+			"nextcloud_field_casing_variant_total",
+			"Number of serverinfo fields matched despite a non-canonical key casing",
+			nil,
 		),
 	}
+	m.names = names
+	m.help = helpByName
+	return m
+}
+
+// serverinfoValueDescs returns the unlabeled descriptors whose values come directly from serverinfo
+func (m *MetricDescriptors) serverinfoValueDescs() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		m.FreeSpace,
+		m.CPUCount,
+		m.MemTotal,
+		m.MemFree,
+		m.SwapTotal,
+		m.SwapFree,
+		m.AppsInstalled,
+		m.AppsUpdatesAvailable,
+		m.UsersTotal,
+		m.FilesTotal,
+		m.StoragesTotal,
+		m.StoragesLocalTotal,
+		m.StoragesHomeTotal,
+		m.StoragesOtherTotal,
+		m.SharesTotal,
+		m.SharesUserTotal,
+		m.SharesGroupsTotal,
+		m.SharesLinkTotal,
+		m.SharesMailTotal,
+		m.SharesRoomTotal,
+		m.SharesLinkNoPasswordTotal,
+		m.SharesFederatedSentTotal,
+		m.SharesFederatedReceivedTotal,
+		m.PHPMemoryLimit,
+		m.PHPUploadMaxFilesize,
+		m.PHPOpcacheMemoryUsed,
+		m.PHPOpcacheMemoryFree,
+		m.PHPOpcacheMemoryWasted,
+		m.PHPOpcacheHitRate,
+		m.DatabaseSize,
+	}
 }
 
 // DescribeAll sends all metric descriptors to the channel
@@ -287,38 +752,111 @@ func (m *MetricDescriptors) DescribeAll(ch chan<- *prometheus.Desc) {
 	ch <- m.StatusMaintenance
 	ch <- m.StatusNeedsDbUpgrade
 	ch <- m.StatusExtendedSupport
+	ch <- m.MaintenanceStateConsistent
 	ch <- m.SystemInfo
 	ch <- m.FreeSpace
+	ch <- m.FreeSpaceBelowThreshold
 	ch <- m.CPULoad
+	ch <- m.CPULoadSmoothed
 	ch <- m.CPUCount
 	ch <- m.MemTotal
 	ch <- m.MemFree
 	ch <- m.SwapTotal
 	ch <- m.SwapFree
+	ch <- m.SwapConfigured
+	ch <- m.CacheBackendInfo
+	ch <- m.DebugEnabled
+	ch <- m.LocaleInfo
 	ch <- m.AppsInstalled
 	ch <- m.AppsUpdatesAvailable
+	ch <- m.AppsSecurityUpdatesAvailable
+	ch <- m.AppInfo
 	ch <- m.UpdateAvailable
+	ch <- m.UpdatesAvailable
 	ch <- m.UsersTotal
+	ch <- m.UsersAdded
 	ch <- m.FilesTotal
+	ch <- m.FilesPerUser
+	ch <- m.FilesByStorage
 	ch <- m.StoragesTotal
 	ch <- m.StoragesLocalTotal
 	ch <- m.StoragesHomeTotal
 	ch <- m.StoragesOtherTotal
+	ch <- m.StoragesExternalTotal
 	ch <- m.SharesTotal
+	ch <- m.SharesCreatedDelta
 	ch <- m.SharesUserTotal
 	ch <- m.SharesGroupsTotal
 	ch <- m.SharesLinkTotal
 	ch <- m.SharesMailTotal
 	ch <- m.SharesRoomTotal
+	ch <- m.SharesRoomRatio
 	ch <- m.SharesLinkNoPasswordTotal
+	ch <- m.SharesLinkNoPasswordRatio
+	ch <- m.SharesLinkNoPasswordExceedsThreshold
+	ch <- m.SharesLinkToUserRatio
 	ch <- m.SharesFederatedSentTotal
 	ch <- m.SharesFederatedReceivedTotal
 	ch <- m.PHPMemoryLimit
+	ch <- m.PHPInfo
+	ch <- m.PHPVersionEOL
+	ch <- m.UsersPerPHPMemoryMB
 	ch <- m.PHPUploadMaxFilesize
 	ch <- m.PHPOpcacheMemoryUsed
+	ch <- m.PHPOpcacheMemoryUsedMax
+	ch <- m.PHPOpcacheJITBufferUsed
+	ch <- m.PHPOpcacheJITBufferFree
 	ch <- m.PHPOpcacheMemoryFree
+	ch <- m.PHPOpcacheMemoryWasted
 	ch <- m.PHPOpcacheHitRate
+	ch <- m.PHPOpcacheHits
+	ch <- m.PHPOpcacheMisses
+	ch <- m.PHPOpcacheBlacklistMissRatio
+	ch <- m.PHPOpcacheRestarts
+	ch <- m.PHPOpcacheLastRestart
 	ch <- m.DatabaseSize
+	ch <- m.DatabaseInfo
+	ch <- m.DatabaseSizeAvailable
+	ch <- m.DatabaseMissingIndices
+	ch <- m.DatabasePendingBigintConversions
 	ch <- m.ActiveUsers
+	ch <- m.ActiveUsersDailyGrowth
+	ch <- m.Capability
+	ch <- m.ActivityLatestID
+	ch <- m.UpstreamTLSCertExpiry
+	ch <- m.UpstreamTLSCertNotAfter
+	ch <- m.UpstreamTLSEnabled
+	ch <- m.UpstreamBytesRead
+	ch <- m.AuthResults
+	ch <- m.ExporterAuthConfigured
+	ch <- m.ExporterFeaturesInfo
+	ch <- m.ExporterConfigHash
+	ch <- m.ExporterTargetInfo
+	ch <- m.ExporterScrapeTime
 	ch <- m.ScrapeSuccess
+	ch <- m.ScrapeError
+	ch <- m.ScrapeTimedOut
+	ch <- m.ServerinfoEmptyData
+	ch <- m.SuspiciousZeroPayload
+	ch <- m.CachePartial
+	ch <- m.CacheValidFor
+	ch <- m.ScrapesTotal
+	ch <- m.ScrapeInFlight
+	ch <- m.CollectPanicTotal
+	ch <- m.InvalidMetricValues
+	ch <- m.UnknownFieldsTotal
+	ch <- m.FieldCasingVariants
+}
+
+// checkUnique reports an error when two descriptors share a metric name, which
+// would otherwise only surface as a registration panic
+func (m *MetricDescriptors) checkUnique() error {
+	seen := map[string]bool{}
+	for _, name := range m.names {
+		if seen[name] {
+			return fmt.Errorf("duplicate metric descriptor %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
 }
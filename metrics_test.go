@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricDescriptorsUnique(t *testing.T) {
+	if err := NewMetricDescriptors().checkUnique(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckUniqueReportsDuplicates(t *testing.T) {
+	m := NewMetricDescriptors()
+	m.names = append(m.names, "nextcloud_users_total")
+	if err := m.checkUnique(); err == nil {
+		t.Fatal("duplicate nextcloud_users_total not reported")
+	}
+}
+
+func TestMetricDescriptorNamesCoverDescribeAll(t *testing.T) {
+	m := NewMetricDescriptors()
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		m.DescribeAll(ch)
+		close(ch)
+	}()
+	described := 0
+	for range ch {
+		described++
+	}
+	if described != len(m.names) {
+		t.Errorf("DescribeAll sends %d descriptors, %d names recorded", described, len(m.names))
+	}
+}
+
+// unboundedRatios are _ratio metrics that are not fractions, so their help
+// text does not say (0-1)
+var unboundedRatios = map[string]bool{
+	"nextcloud_shares_link_to_user_ratio": true,
+}
+
+func TestHelpTextUnits(t *testing.T) {
+	m := NewMetricDescriptors()
+	for _, name := range m.names {
+		help := m.help[name]
+		switch {
+		case strings.HasSuffix(name, "_bytes") && !strings.Contains(help, "in bytes"):
+			t.Errorf("%s: help %q does not say \"in bytes\"", name, help)
+		case strings.HasSuffix(name, "_ratio") && !unboundedRatios[name] && !strings.Contains(help, "(0-1)"):
+			t.Errorf("%s: help %q does not say \"(0-1)\"", name, help)
+		case strings.HasSuffix(name, "_seconds") && !strings.HasSuffix(help, "in seconds"):
+			t.Errorf("%s: help %q does not say \"in seconds\"", name, help)
+		}
+	}
+}
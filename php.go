@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// phpEOLDates maps PHP major.minor versions to the end of their security support.
+// Update when php.net announces new releases or extends support.
+var phpEOLDates = map[string]string{
+	"7.0": "2019-01-10",
+	"7.1": "2019-12-01",
+	"7.2": "2020-11-30",
+	"7.3": "2021-12-06",
+	"7.4": "2022-11-28",
+	"8.0": "2023-11-26",
+	"8.1": "2025-12-31",
+	"8.2": "2026-12-31",
+	"8.3": "2027-12-31",
+	"8.4": "2028-12-31",
+	"8.5": "2029-12-31",
+}
+
+// phpMinorVersion returns the major.minor part of a PHP version such as "8.2.15", or ""
+func phpMinorVersion(version string) string {
+	parts := strings.SplitN(strings.TrimSpace(version), ".", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// phpEOL looks up the end-of-life date of a PHP version and reports whether it has
+// passed at now. ok is false when the version cannot be parsed or is not in the table.
+func phpEOL(version string, now time.Time) (eolDate string, eol bool, ok bool) {
+	eolDate, ok = phpEOLDates[phpMinorVersion(version)]
+	if !ok {
+		return "", false, false
+	}
+	end, err := time.Parse(time.DateOnly, eolDate)
+	if err != nil {
+		return "", false, false
+	}
+	// Support ends at the end of the listed day
+	return eolDate, !now.Before(end.AddDate(0, 0, 1)), true
+}
@@ -0,0 +1,51 @@
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// PublicCollector exposes only the availability metrics of an instance, for
+// sharing a limited status feed on /public-metrics without the serverinfo details
+type PublicCollector struct {
+	collector *NextcloudCollector
+}
+
+// NewPublicCollector creates a collector that filters the given instance collector
+func NewPublicCollector(collector *NextcloudCollector) *PublicCollector {
+	return &PublicCollector{collector: collector}
+}
+
+// publicDescs returns the descriptors that are passed through
+func (p *PublicCollector) publicDescs() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		p.collector.metrics.ScrapeSuccess,
+		p.collector.metrics.StatusMaintenance,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (p *PublicCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range p.publicDescs() {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector. It fetches through the instance
+// collector's caches, so the endpoint stays current when only it is scraped,
+// but leaves the scrape bookkeeping (scrape counters, in-flight gauge) alone.
+// scrape_success is 1 only when the last serverinfo fetch of this process
+// succeeded: data restored from -cache-file or served after a failed fetch
+// does not count.
+func (p *PublicCollector) Collect(ch chan<- prometheus.Metric) {
+	c := p.collector
+	status, _, statusErr := c.fetchStatusCached()
+	_, _, dataErr := c.fetchDataCached()
+
+	c.cacheMu.RLock()
+	lastErr := c.lastDataErr
+	c.cacheMu.RUnlock()
+	up := dataErr == nil && lastErr == nil && c.Ready()
+
+	ch <- prometheus.MustNewConstMetric(c.metrics.ScrapeSuccess, prometheus.GaugeValue, boolToFloat(up))
+	if statusErr == nil {
+		ch <- prometheus.MustNewConstMetric(c.metrics.StatusMaintenance, prometheus.GaugeValue, boolToFloat(status.Maintenance))
+	}
+}
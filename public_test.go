@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// publicScrapeSuccess is the expected scrape_success series, with the value as %s
+const publicScrapeSuccess = `
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success %s
+`
+
+func TestPublicCollectorFetchesOnItsOwn(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+
+	compareMetrics(t, NewPublicCollector(c), `
+# HELP nextcloud_scrape_success Whether the scrape was successful (1 = success, 0 = failure)
+# TYPE nextcloud_scrape_success gauge
+nextcloud_scrape_success 1
+# HELP nextcloud_status_maintenance Nextcloud maintenance mode (1 = enabled, 0 = disabled)
+# TYPE nextcloud_status_maintenance gauge
+nextcloud_status_maintenance 0
+`)
+	if n := c.scrapes.Load(); n != 0 {
+		t.Errorf("scrapes_total moved to %d", n)
+	}
+}
+
+func TestPublicCollectorUpstreamDown(t *testing.T) {
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+	upstream.Close()
+
+	compareMetrics(t, NewPublicCollector(c), fmt.Sprintf(publicScrapeSuccess, "0"))
+}
+
+func TestPublicCollectorUpstreamGoesDown(t *testing.T) {
+	// Data cached by an earlier fetch does not keep the feed at 1
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, func(config *Config) {
+		config.FetchInterval = time.Nanosecond
+	})
+	public := NewPublicCollector(c)
+	compareMetrics(t, public, fmt.Sprintf(publicScrapeSuccess, "1"), "nextcloud_scrape_success")
+
+	upstream.Close()
+	compareMetrics(t, public, fmt.Sprintf(publicScrapeSuccess, "0"), "nextcloud_scrape_success")
+}
+
+func TestPublicCollectorRestoredCache(t *testing.T) {
+	// Data restored from the cache file is not proof that the upstream is up
+	upstream := newFakeNextcloud(t, "serverinfo.json", nil)
+	c := newTestCollector(upstream, nil)
+	cache := &cacheFile{path: t.TempDir() + "/cache.json", maxAge: time.Hour, entries: map[string]cacheEntry{
+		upstream.URL: {Data: &OCSResponse{}, DataTime: time.Now()},
+	}}
+	c.restoreCache(cache)
+	upstream.Close()
+
+	compareMetrics(t, NewPublicCollector(c), fmt.Sprintf(publicScrapeSuccess, "0"), "nextcloud_scrape_success")
+}
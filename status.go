@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sourceStatus is the health of one upstream data source in the /status report
+type sourceStatus struct {
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	AgeSeconds  *float64   `json:"age_seconds,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// instanceStatus is the /status report of a single instance
+type instanceStatus struct {
+	URL        string       `json:"url"`
+	Status     sourceStatus `json:"status"`
+	Serverinfo sourceStatus `json:"serverinfo"`
+	Ready      bool         `json:"ready"`
+}
+
+// newSourceStatus builds the report of a source from its last successful fetch time and last error
+func newSourceStatus(lastSuccess time.Time, lastErr error) sourceStatus {
+	var s sourceStatus
+	if !lastSuccess.IsZero() {
+		age := time.Since(lastSuccess).Seconds()
+		s.LastSuccess = &lastSuccess
+		s.AgeSeconds = &age
+	}
+	if lastErr != nil {
+		s.LastError = lastErr.Error()
+	}
+	return s
+}
+
+// statusReport returns a snapshot of the collector's fetch state
+func (c *NextcloudCollector) statusReport() instanceStatus {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+
+	return instanceStatus{
+		URL:        redactURL(c.instance.BaseURL),
+		Status:     newSourceStatus(c.lastStatusFetch, c.lastStatusErr),
+		Serverinfo: newSourceStatus(c.lastFetchTime, c.lastDataErr),
+		Ready:      c.Ready(),
+	}
+}
+
+// redactURL hides any password embedded in a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// statusHandler serves the fetch state of every instance as JSON
+func statusHandler(collectors []*NextcloudCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances := make([]instanceStatus, len(collectors))
+		for i, collector := range collectors {
+			instances[i] = collector.statusReport()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"instances": instances})
+	}
+}
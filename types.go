@@ -1,110 +1,289 @@
 package main
 
-// OCSResponse is the main response structure from Nextcloud serverinfo API
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OCSResponse is the main response structure from Nextcloud serverinfo API.
+// The xml tags mirror the json tags for proxies that force format=xml.
 type OCSResponse struct {
 	OCS struct {
 		Meta struct {
-			Status     string `json:"status"`
-			StatusCode int    `json:"statuscode"`
-			Message    string `json:"message"`
-		} `json:"meta"`
+			Status     string `json:"status" xml:"status"`
+			StatusCode int    `json:"statuscode" xml:"statuscode"`
+			Message    string `json:"message" xml:"message"`
+		} `json:"meta" xml:"meta"`
 		Data struct {
-			Nextcloud   NextcloudData   `json:"nextcloud"`
-			Server      ServerData      `json:"server"`
-			ActiveUsers ActiveUsersData `json:"activeUsers"`
-		} `json:"data"`
+			Nextcloud   NextcloudData   `json:"nextcloud" xml:"nextcloud"`
+			Server      ServerData      `json:"server" xml:"server"`
+			ActiveUsers ActiveUsersData `json:"activeUsers" xml:"activeUsers"`
+		} `json:"data" xml:"data"`
 	} `json:"ocs"`
 }
 
 // NextcloudData contains system, storage, and shares information
 type NextcloudData struct {
-	System  SystemData  `json:"system"`
-	Storage StorageData `json:"storage"`
-	Shares  SharesData  `json:"shares"`
+	System  SystemData  `json:"system" xml:"system"`
+	Storage StorageData `json:"storage" xml:"storage"`
+	Shares  SharesData  `json:"shares" xml:"shares"`
 }
 
 // SystemData contains system-level information
 type SystemData struct {
-	Version   string    `json:"version"`
-	FreeSpace int64     `json:"freespace"`
-	CPULoad   []float64 `json:"cpuload"`
-	CPUNum    int       `json:"cpunum"`
-	MemTotal  int64     `json:"mem_total"`
-	MemFree   int64     `json:"mem_free"`
-	SwapTotal int64     `json:"swap_total"`
-	SwapFree  int64     `json:"swap_free"`
-	Apps      struct {
-		NumInstalled        int `json:"num_installed"`
-		NumUpdatesAvailable int `json:"num_updates_available"`
-	} `json:"apps"`
+	Version   string       `json:"version" xml:"version"`
+	Channel   string       `json:"channel" xml:"channel"`
+	FreeSpace ByteCount    `json:"freespace" xml:"freespace"`
+	CPULoad   LoadAverages `json:"cpuload" xml:"cpuload>element"`
+	CPUNum    int          `json:"cpunum" xml:"cpunum"`
+	MemTotal  int64        `json:"mem_total" xml:"mem_total"`
+	MemFree   int64        `json:"mem_free" xml:"mem_free"`
+	SwapTotal int64        `json:"swap_total" xml:"swap_total"`
+	SwapFree  int64        `json:"swap_free" xml:"swap_free"`
+
+	// Configured memcache classes (e.g. \OC\Memcache\Redis or "none"), empty when not reported
+	MemcacheLocal       string `json:"memcache.local" xml:"memcache.local"`
+	MemcacheDistributed string `json:"memcache.distributed" xml:"memcache.distributed"`
+	MemcacheLocking     string `json:"memcache.locking" xml:"memcache.locking"`
+
+	// Configured localization defaults, empty when not reported
+	DefaultTimezone    string `json:"default_timezone" xml:"default_timezone"`
+	DefaultPhoneRegion string `json:"default_phone_region" xml:"default_phone_region"`
+
+	// Whether debug mode is enabled in config.php, nil when not reported
+	Debug *bool `json:"debug" xml:"debug"`
+
+	Apps struct {
+		NumInstalled        int `json:"num_installed" xml:"num_installed"`
+		NumUpdatesAvailable int `json:"num_updates_available" xml:"num_updates_available"`
+
+		// Only reported by versions that distinguish security updates
+		NumSecurityUpdatesAvailable OptionalFloat `json:"num_security_updates_available" xml:"num_security_updates_available"`
+
+		// Installed apps, when the apps sub-query lists them. Kept raw since the
+		// shape differs between versions; see installedApps.
+		Installed json.RawMessage `json:"installed" xml:"-"`
+	} `json:"apps" xml:"apps"`
 	Update struct {
-		Available        bool   `json:"available"`
-		AvailableVersion string `json:"available_version"`
-	} `json:"update"`
+		Available        bool   `json:"available" xml:"available"`
+		AvailableVersion string `json:"available_version" xml:"available_version"`
+	} `json:"update" xml:"update"`
+}
+
+// systemDataFallbacks maps SystemData keys to spellings that some versions or
+// proxies use instead, beyond a different casing (which encoding/json already
+// matches). A fallback is only read when the canonical key is missing.
+var systemDataFallbacks = map[string]string{
+	"freespace": "free_space",
+}
+
+// UnmarshalJSON decodes SystemData, reading the keys in systemDataFallbacks
+// for fields the payload does not carry under their canonical key
+func (s *SystemData) UnmarshalJSON(b []byte) error {
+	type plain SystemData
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	fields := map[string]any{
+		"freespace": &s.FreeSpace,
+	}
+	for key, fallback := range systemDataFallbacks {
+		if _, ok := lookupFold(raw, key); ok {
+			continue
+		}
+		value, ok := lookupFold(raw, fallback)
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, fields[key]); err != nil {
+			return fmt.Errorf("decoding %s: %w", fallback, err)
+		}
+	}
+	return nil
+}
+
+// lookupFold returns the value of key in raw, matched case-insensitively like
+// encoding/json does
+func lookupFold(raw map[string]json.RawMessage, key string) (json.RawMessage, bool) {
+	if value, ok := raw[key]; ok {
+		return value, true
+	}
+	for k, value := range raw {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
 }
 
 // StorageData contains storage statistics
 type StorageData struct {
-	NumUsers         int `json:"num_users"`
-	NumFiles         int `json:"num_files"`
-	NumStorages      int `json:"num_storages"`
-	NumStoragesLocal int `json:"num_storages_local"`
-	NumStoragesHome  int `json:"num_storages_home"`
-	NumStoragesOther int `json:"num_storages_other"`
+	NumUsers         int `json:"num_users" xml:"num_users"`
+	NumFiles         int `json:"num_files" xml:"num_files"`
+	NumStorages      int `json:"num_storages" xml:"num_storages"`
+	NumStoragesLocal int `json:"num_storages_local" xml:"num_storages_local"`
+	NumStoragesHome  int `json:"num_storages_home" xml:"num_storages_home"`
+	NumStoragesOther int `json:"num_storages_other" xml:"num_storages_other"`
+
+	// File counts per storage type, only reported by some versions
+	NumFilesHome  OptionalFloat `json:"num_files_home" xml:"num_files_home"`
+	NumFilesLocal OptionalFloat `json:"num_files_local" xml:"num_files_local"`
+	NumFilesOther OptionalFloat `json:"num_files_other" xml:"num_files_other"`
+
+	// External storage mounts (S3, SMB, ...), only reported by some versions
+	NumStoragesExternal OptionalFloat `json:"num_storages_external" xml:"num_storages_external"`
 }
 
 // SharesData contains sharing statistics
 type SharesData struct {
-	NumShares               int `json:"num_shares"`
-	NumSharesUser           int `json:"num_shares_user"`
-	NumSharesGroups         int `json:"num_shares_groups"`
-	NumSharesLink           int `json:"num_shares_link"`
-	NumSharesMail           int `json:"num_shares_mail"`
-	NumSharesRoom           int `json:"num_shares_room"`
-	NumSharesLinkNoPassword int `json:"num_shares_link_no_password"`
-	NumFedSharesSent        int `json:"num_fed_shares_sent"`
-	NumFedSharesReceived    int `json:"num_fed_shares_received"`
+	NumShares               int `json:"num_shares" xml:"num_shares"`
+	NumSharesUser           int `json:"num_shares_user" xml:"num_shares_user"`
+	NumSharesGroups         int `json:"num_shares_groups" xml:"num_shares_groups"`
+	NumSharesLink           int `json:"num_shares_link" xml:"num_shares_link"`
+	NumSharesMail           int `json:"num_shares_mail" xml:"num_shares_mail"`
+	NumSharesRoom           int `json:"num_shares_room" xml:"num_shares_room"`
+	NumSharesLinkNoPassword int `json:"num_shares_link_no_password" xml:"num_shares_link_no_password"`
+	NumFedSharesSent        int `json:"num_fed_shares_sent" xml:"num_fed_shares_sent"`
+	NumFedSharesReceived    int `json:"num_fed_shares_received" xml:"num_fed_shares_received"`
 }
 
 // ServerData contains server configuration information
 type ServerData struct {
-	Webserver string `json:"webserver"`
+	Webserver string `json:"webserver" xml:"webserver"`
 	PHP       struct {
-		Version           string `json:"version"`
-		MemoryLimit       int64  `json:"memory_limit"`
-		MaxExecutionTime  int    `json:"max_execution_time"`
-		UploadMaxFilesize int64  `json:"upload_max_filesize"`
+		Version           string      `json:"version" xml:"version"`
+		MemoryLimit       PHPByteSize `json:"memory_limit" xml:"memory_limit"`
+		MaxExecutionTime  int         `json:"max_execution_time" xml:"max_execution_time"`
+		UploadMaxFilesize PHPByteSize `json:"upload_max_filesize" xml:"upload_max_filesize"`
 		OPcache           struct {
-			OPcacheEnabled bool `json:"opcache_enabled"`
+			OPcacheEnabled bool `json:"opcache_enabled" xml:"opcache_enabled"`
 			MemoryUsage    struct {
-				UsedMemory   int64 `json:"used_memory"`
-				FreeMemory   int64 `json:"free_memory"`
-				WastedMemory int64 `json:"wasted_memory"`
-			} `json:"memory_usage"`
+				UsedMemory   int64 `json:"used_memory" xml:"used_memory"`
+				FreeMemory   int64 `json:"free_memory" xml:"free_memory"`
+				WastedMemory int64 `json:"wasted_memory" xml:"wasted_memory"`
+			} `json:"memory_usage" xml:"memory_usage"`
 			OPcacheStatistics struct {
-				Hits           int64   `json:"hits"`
-				Misses         int64   `json:"misses"`
-				OPcacheHitRate float64 `json:"opcache_hit_rate"`
-			} `json:"opcache_statistics"`
-		} `json:"opcache"`
-	} `json:"php"`
+				Hits               int64         `json:"hits" xml:"hits"`
+				Misses             int64         `json:"misses" xml:"misses"`
+				OPcacheHitRate     float64       `json:"opcache_hit_rate" xml:"opcache_hit_rate"`
+				BlacklistMissRatio OptionalFloat `json:"blacklist_miss_ratio" xml:"blacklist_miss_ratio"`
+				OOMRestarts        OptionalFloat `json:"oom_restarts" xml:"oom_restarts"`
+				HashRestarts       OptionalFloat `json:"hash_restarts" xml:"hash_restarts"`
+				ManualRestarts     OptionalFloat `json:"manual_restarts" xml:"manual_restarts"`
+				LastRestartTime    OptionalFloat `json:"last_restart_time" xml:"last_restart_time"`
+			} `json:"opcache_statistics" xml:"opcache_statistics"`
+
+			// JIT buffer statistics, only reported when the PHP build has JIT
+			JIT struct {
+				BufferSize OptionalFloat `json:"buffer_size" xml:"buffer_size"`
+				BufferFree OptionalFloat `json:"buffer_free" xml:"buffer_free"`
+			} `json:"jit" xml:"jit"`
+		} `json:"opcache" xml:"opcache"`
+	} `json:"php" xml:"php"`
 	Database struct {
-		Type    string `json:"type"`
+		Type    string `json:"type" xml:"type"`
+		Version string `json:"version" xml:"version"`
+
+		// Reported as a number or a numeric string depending on the database; SQLite may report ""
+		Size OptionalFloat `json:"size" xml:"size"`
+
+		// Admin to-do items, only reported by newer versions
+		MissingIndices           OptionalFloat `json:"missing_indices" xml:"missing_indices"`
+		PendingBigintConversions OptionalFloat `json:"pending_bigint_conversions" xml:"pending_bigint_conversions"`
+	} `json:"database" xml:"database"`
+}
+
+// installedApps returns the installed app ids and versions. It understands a
+// map of id to version, a map of id to an object with a version, and a list of
+// objects with id and version (or a list of ids); other shapes yield no apps.
+func (s SystemData) installedApps() map[string]string {
+	raw := s.Apps.Installed
+	apps := map[string]string{}
+
+	var versions map[string]string
+	if json.Unmarshal(raw, &versions) == nil {
+		return versions
+	}
+	var objects map[string]struct {
 		Version string `json:"version"`
-		Size    string `json:"size"`
-	} `json:"database"`
+	}
+	if json.Unmarshal(raw, &objects) == nil {
+		for id, app := range objects {
+			apps[id] = app.Version
+		}
+		return apps
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) != nil {
+		return apps
+	}
+	for _, item := range list {
+		var app struct {
+			ID      string `json:"id"`
+			Version string `json:"version"`
+		}
+		var id string
+		switch {
+		case json.Unmarshal(item, &app) == nil && app.ID != "":
+			apps[app.ID] = app.Version
+		case json.Unmarshal(item, &id) == nil && id != "":
+			apps[id] = ""
+		}
+	}
+	return apps
 }
 
 // ActiveUsersData contains active user statistics
 type ActiveUsersData struct {
-	Last5Minutes int `json:"last5minutes"`
-	Last1Hour    int `json:"last1hour"`
-	Last24Hours  int `json:"last24hours"`
-	Last7Days    int `json:"last7days"`
-	Last1Month   int `json:"last1month"`
-	Last3Months  int `json:"last3months"`
-	Last6Months  int `json:"last6months"`
-	LastYear     int `json:"lastyear"`
+	Last5Minutes int `json:"last5minutes" xml:"last5minutes"`
+	Last1Hour    int `json:"last1hour" xml:"last1hour"`
+	Last24Hours  int `json:"last24hours" xml:"last24hours"`
+	Last7Days    int `json:"last7days" xml:"last7days"`
+	Last1Month   int `json:"last1month" xml:"last1month"`
+	Last3Months  int `json:"last3months" xml:"last3months"`
+	Last6Months  int `json:"last6months" xml:"last6months"`
+	LastYear     int `json:"lastyear" xml:"lastyear"`
+}
+
+// byPeriod returns the active user counts keyed by nextcloud_active_users period label
+func (u ActiveUsersData) byPeriod() map[string]int {
+	return map[string]int{
+		"5min":    u.Last5Minutes,
+		"1hour":   u.Last1Hour,
+		"24hours": u.Last24Hours,
+		"7days":   u.Last7Days,
+		"1month":  u.Last1Month,
+		"3months": u.Last3Months,
+		"6months": u.Last6Months,
+		"1year":   u.LastYear,
+	}
+}
+
+// ActiveUsersResponse is the response from /ocs/v2.php/apps/serverinfo/api/v1/activeUsers.
+// The counts are either wrapped in an activeUsers object or are the data object itself.
+type ActiveUsersResponse struct {
+	OCS struct {
+		Data struct {
+			ActiveUsersData
+			ActiveUsers *ActiveUsersData `json:"activeUsers"`
+		} `json:"data"`
+	} `json:"ocs"`
+}
+
+// activeUsers returns the counts from whichever shape the response used
+func (r *ActiveUsersResponse) activeUsers() *ActiveUsersData {
+	if r.OCS.Data.ActiveUsers != nil {
+		return r.OCS.Data.ActiveUsers
+	}
+	return &r.OCS.Data.ActiveUsersData
 }
 
 // StatusResponse is the response from /status.php
@@ -118,3 +297,156 @@ type StatusResponse struct {
 	ProductName     string `json:"productname"`
 	ExtendedSupport bool   `json:"extendedSupport"`
 }
+
+// CapabilitiesResponse is the response from /ocs/v2.php/cloud/capabilities.
+// Capabilities are kept generic since each app contributes its own tree.
+type CapabilitiesResponse struct {
+	OCS struct {
+		Data struct {
+			Capabilities map[string]any `json:"capabilities"`
+		} `json:"data"`
+	} `json:"ocs"`
+}
+
+// OptionalFloat is a number that some serverinfo versions omit. Valid is false
+// when the field is absent, null, or not a number, so a malformed optional
+// field never fails the whole decode.
+type OptionalFloat struct {
+	Value float64
+	Valid bool
+}
+
+// UnmarshalJSON accepts JSON numbers and numeric strings
+func (f *OptionalFloat) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(bytes.TrimSpace(b), `"`)
+	return f.UnmarshalText(b)
+}
+
+// MarshalJSON writes the value as a number, or null when absent
+func (f OptionalFloat) MarshalJSON() ([]byte, error) {
+	if !f.Valid {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, f.Value, 'g', -1, 64), nil
+}
+
+// UnmarshalText accepts a plain number, as found in XML element text. NaN and
+// infinities are treated as absent so they are never emitted.
+func (f *OptionalFloat) UnmarshalText(b []byte) error {
+	v, err := strconv.ParseFloat(string(bytes.TrimSpace(b)), 64)
+	*f = OptionalFloat{Value: v, Valid: err == nil && !math.IsNaN(v) && !math.IsInf(v, 0)}
+	return nil
+}
+
+// LoadAverages are the serverinfo CPU load averages. A NaN or infinity, which
+// only XML can carry, is written as null so the cache file stays valid JSON,
+// and a null is read back as NaN so it is still skipped when collecting.
+type LoadAverages []float64
+
+// MarshalJSON writes the load averages as a JSON array with null for non-finite values
+func (l LoadAverages) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("null"), nil
+	}
+	values := make([]*float64, len(l))
+	for i, v := range l {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			values[i] = &l[i]
+		}
+	}
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON reads a JSON array of numbers, with null read as NaN
+func (l *LoadAverages) UnmarshalJSON(b []byte) error {
+	var values []*float64
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	if values == nil {
+		*l = nil
+		return nil
+	}
+	*l = make(LoadAverages, len(values))
+	for i, v := range values {
+		(*l)[i] = math.NaN()
+		if v != nil {
+			(*l)[i] = *v
+		}
+	}
+	return nil
+}
+
+// ByteCount is a byte count that some serverinfo versions report as a float or
+// a numeric string (e.g. "12345.0") instead of an integer
+type ByteCount int64
+
+// UnmarshalJSON accepts JSON integers, floats and numeric strings. null is
+// treated as absent and leaves the value unchanged.
+func (b *ByteCount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	return b.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// UnmarshalText accepts a plain number, as found in XML element text. Empty
+// text is treated as absent.
+func (b *ByteCount) UnmarshalText(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(bytes.TrimSpace(data)), 64)
+	if err != nil {
+		return fmt.Errorf("invalid byte count %q", data)
+	}
+	*b = ByteCount(v)
+	return nil
+}
+
+// PHPByteSize is a php.ini size that some servers report in shorthand notation
+// (e.g. "512M", "2G") instead of bytes. -1 means unlimited.
+type PHPByteSize int64
+
+// UnmarshalJSON accepts JSON integers and shorthand strings. null is treated
+// as absent and leaves the value unchanged.
+func (s *PHPByteSize) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	return s.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// UnmarshalText accepts a byte count with an optional K, M or G suffix, as
+// php.ini does. Empty text is treated as absent.
+func (s *PHPByteSize) UnmarshalText(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return nil
+	}
+	multiplier := int64(1)
+	switch text[len(text)-1] {
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		text = text[:len(text)-1]
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid PHP byte size %q", data)
+	}
+	if v < 0 {
+		// Unlimited is -1 whatever the suffix
+		*s = -1
+		return nil
+	}
+	*s = PHPByteSize(v * multiplier)
+	return nil
+}
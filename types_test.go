@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+)
+
+func TestPHPByteSizeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  PHPByteSize
+	}{
+		{`"512M"`, 512 << 20},
+		{`"2G"`, 2 << 30},
+		{`"128k"`, 128 << 10},
+		{`"-1"`, -1},
+		{`-1`, -1},
+		{`536870912`, 536870912},
+		{`"536870912"`, 536870912},
+	}
+	for _, tt := range tests {
+		var got PHPByteSize
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPHPByteSizeAbsent(t *testing.T) {
+	for _, input := range []string{`null`, `""`} {
+		got := PHPByteSize(42)
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Errorf("%s: %v", input, err)
+		}
+		if got != 42 {
+			t.Errorf("%s: got %d, want the value unchanged", input, got)
+		}
+	}
+}
+
+func TestPHPByteSizeInvalid(t *testing.T) {
+	var got PHPByteSize
+	if err := json.Unmarshal([]byte(`"lots"`), &got); err == nil {
+		t.Errorf("got %d, want an error", got)
+	}
+}
+
+func TestByteCountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ByteCount
+	}{
+		{`12345`, 12345},
+		{`12345.0`, 12345},
+		{`"12345.0"`, 12345},
+		{`"12345"`, 12345},
+		{`1.2345e4`, 12345},
+	}
+	for _, tt := range tests {
+		var got ByteCount
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestByteCountAbsent(t *testing.T) {
+	for _, input := range []string{`null`, `""`} {
+		got := ByteCount(42)
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Errorf("%s: %v", input, err)
+		}
+		if got != 42 {
+			t.Errorf("%s: got %d, want the value unchanged", input, got)
+		}
+	}
+}
+
+func TestInstalledApps(t *testing.T) {
+	tests := []struct {
+		installed string
+		want      map[string]string
+	}{
+		{`{"files": "2.0.0"}`, map[string]string{"files": "2.0.0"}},
+		{`{"files": {"version": "2.0.0", "enabled": true}}`, map[string]string{"files": "2.0.0"}},
+		{`[{"id": "files", "version": "2.0.0"}]`, map[string]string{"files": "2.0.0"}},
+		{`["files"]`, map[string]string{"files": ""}},
+		{`true`, map[string]string{}},
+		{``, map[string]string{}},
+	}
+	for _, tt := range tests {
+		var system SystemData
+		system.Apps.Installed = json.RawMessage(tt.installed)
+		if got := system.installedApps(); !maps.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestOptionalFloatUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  OptionalFloat
+	}{
+		{`12`, OptionalFloat{Value: 12, Valid: true}},
+		{`"12.5"`, OptionalFloat{Value: 12.5, Valid: true}},
+		{`null`, OptionalFloat{}},
+		{`""`, OptionalFloat{}},
+		{`"NaN"`, OptionalFloat{}},
+		{`"Inf"`, OptionalFloat{}},
+		{`"+Inf"`, OptionalFloat{}},
+		{`"-Inf"`, OptionalFloat{}},
+	}
+	for _, tt := range tests {
+		var got OptionalFloat
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.Valid != tt.want.Valid || (got.Valid && got.Value != tt.want.Value) {
+			t.Errorf("%s: got %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestOptionalFloatUnmarshalTextNonFinite(t *testing.T) {
+	for _, input := range []string{"NaN", "Inf", "+Inf", "-Inf"} {
+		var got OptionalFloat
+		if err := got.UnmarshalText([]byte(input)); err != nil {
+			t.Errorf("%s: %v", input, err)
+		}
+		if got.Valid {
+			t.Errorf("%s: got a valid value %v", input, got.Value)
+		}
+	}
+}
+
+func TestSystemDataFreeSpaceSpellings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ByteCount
+	}{
+		{`{"freespace": 100}`, 100},
+		{`{"freeSpace": 100}`, 100},
+		{`{"FREESPACE": 100}`, 100},
+		{`{"free_space": 100}`, 100},
+		{`{"Free_Space": "100"}`, 100},
+		// The canonical key wins over the fallback
+		{`{"free_space": 1, "freespace": 100}`, 100},
+		{`{}`, 0},
+	}
+	for _, tt := range tests {
+		var got SystemData
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.FreeSpace != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.input, got.FreeSpace, tt.want)
+		}
+	}
+}
+
+func TestSystemDataFallbackInvalid(t *testing.T) {
+	var got SystemData
+	if err := json.Unmarshal([]byte(`{"free_space": "lots"}`), &got); err == nil {
+		t.Errorf("got %d, want an error", got.FreeSpace)
+	}
+}